@@ -0,0 +1,78 @@
+package niu
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+var ErrFrameTooLarge = errors.New("frame too large")
+
+const (
+	frameHeaderLength   = 4
+	DefaultMaxFrameSize = 4 << 20 // 4MB
+)
+
+// 在流式连接（如 TCP）上写入消息：每个消息前加上4字节大端长度前缀
+// 非并发安全，多个协程写入同一连接时需自行加锁
+type FrameWriter struct {
+	w            io.Writer
+	maxFrameSize int
+}
+
+// maxFrameSize <= 0 时使用 DefaultMaxFrameSize
+func NewFrameWriter(w io.Writer, maxFrameSize int) *FrameWriter {
+	if maxFrameSize <= 0 {
+		maxFrameSize = DefaultMaxFrameSize
+	}
+	return &FrameWriter{w: w, maxFrameSize: maxFrameSize}
+}
+
+func (fw *FrameWriter) WriteFrame(data []byte) error {
+	if len(data) > fw.maxFrameSize {
+		return ErrFrameTooLarge
+	}
+	// 长度与数据一次写入，避免被拆成两个包
+	out := make([]byte, frameHeaderLength, frameHeaderLength+len(data))
+	binary.BigEndian.PutUint32(out, uint32(len(data)))
+	out = append(out, data...)
+	_, err := fw.w.Write(out)
+	return err
+}
+
+// 从流式连接中读取 FrameWriter 写入的消息，每次读取完整的一帧
+// 非并发安全
+type FrameReader struct {
+	r            io.Reader
+	maxFrameSize int
+	header       [frameHeaderLength]byte
+}
+
+// maxFrameSize <= 0 时使用 DefaultMaxFrameSize
+// 长度前缀超过 maxFrameSize 时直接返回错误，防止恶意的长度导致分配过大的内存
+func NewFrameReader(r io.Reader, maxFrameSize int) *FrameReader {
+	if maxFrameSize <= 0 {
+		maxFrameSize = DefaultMaxFrameSize
+	}
+	return &FrameReader{r: r, maxFrameSize: maxFrameSize}
+}
+
+// 读取一帧数据。连接在帧中间断开时返回 io.ErrUnexpectedEOF
+func (fr *FrameReader) ReadFrame() ([]byte, error) {
+	if _, err := io.ReadFull(fr.r, fr.header[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(fr.header[:])
+	if uint64(size) > uint64(fr.maxFrameSize) {
+		return nil, ErrFrameTooLarge
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(fr.r, data); err != nil {
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return data, nil
+}
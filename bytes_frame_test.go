@@ -0,0 +1,72 @@
+package niu
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestFrameRoundTripOverPipe(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	frames := [][]byte{[]byte("hello"), {}, bytes.Repeat([]byte{7}, 70000)}
+
+	go func() {
+		defer client.Close()
+		fw := NewFrameWriter(client, 0)
+		for _, f := range frames {
+			if err := fw.WriteFrame(f); err != nil {
+				return
+			}
+		}
+	}()
+
+	fr := NewFrameReader(server, 0)
+	for i, want := range frames {
+		got, err := fr.ReadFrame()
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("frame %d: got %d bytes, want %d", i, len(got), len(want))
+		}
+	}
+	if _, err := fr.ReadFrame(); err != io.EOF {
+		t.Fatalf("after last frame: %v, want io.EOF", err)
+	}
+}
+
+func TestFrameReaderTruncated(t *testing.T) {
+	header := binary.BigEndian.AppendUint32(nil, 10)
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"empty", nil, io.EOF},
+		{"partial header", header[:2], io.ErrUnexpectedEOF},
+		{"header only", header, io.ErrUnexpectedEOF},
+		{"partial body", append(header, 1, 2, 3), io.ErrUnexpectedEOF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewFrameReader(bytes.NewReader(tt.data), 0).ReadFrame()
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFrameTooLarge(t *testing.T) {
+	if err := NewFrameWriter(io.Discard, 4).WriteFrame([]byte("hello")); !errors.Is(err, ErrFrameTooLarge) {
+		t.Fatalf("write: %v, want ErrFrameTooLarge", err)
+	}
+	data := append(binary.BigEndian.AppendUint32(nil, 5), "hello"...)
+	if _, err := NewFrameReader(bytes.NewReader(data), 4).ReadFrame(); !errors.Is(err, ErrFrameTooLarge) {
+		t.Fatalf("read: %v, want ErrFrameTooLarge", err)
+	}
+}
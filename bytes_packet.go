@@ -1,14 +1,26 @@
 package niu
 
 import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
 	"errors"
+	"fmt"
+	"io"
 	"time"
 )
 
+var (
+	ErrProtocolVersion = errors.New("protocol version mismatch")
+	ErrPayloadTooLarge = errors.New("decompressed payload too large")
+	ErrInvalidMsgType  = errors.New("msgType out of range")
+)
+
+// 数据包格式：msgType(1) + version(1) + requestId(4) + timestamp(8) [+ code(1)] + payload + signature
 type PacketMetaData struct {
-	MsgType   byte  // 1字节
+	MsgType   byte  // 1字节，开启自动识别或压缩时高位为标记，不计入 MsgType
 	RequestId int32 // 4字节
-	Timestamp int32 // 4字节，从2025-01-01 00:00:00开始的秒数
+	Timestamp int64 // 8字节，从2025-01-01 00:00:00 UTC开始的毫秒数
 }
 
 type RequestPacket struct {
@@ -16,66 +28,248 @@ type RequestPacket struct {
 	Payload any
 }
 
-var protocolStartTime = time.Date(2025, 1, 1, 0, 0, 0, 0, time.Local)
+type ResponsePacket struct {
+	PacketMetaData
+	Code    byte // 1字节
+	Payload any
+}
+
+// 时间戳的起始时间，固定为 UTC，与服务器所在时区无关
+var protocolStartTime = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// 数据包的发送时间（UTC）
+func (m *PacketMetaData) Time() time.Time {
+	return protocolStartTime.Add(time.Duration(m.Timestamp) * time.Millisecond)
+}
 
 const (
-	metaLength         = 9
-	responseMetaLength = 10
+	// 协议版本，修改数据包格式时需递增，以便新旧两端能识别出不兼容的数据
+	// v1: 4字节秒级时间戳，起始时间为本地时区
+	// v2: 增加版本字节；8字节毫秒级时间戳，起始时间固定为 UTC；开启压缩时 msgType 次高位用于标记负载已压缩
+	protocolVersion byte = 2
+
+	metaLength         = 14
+	responseMetaLength = 15
 )
 
+// 自动识别格式的协议使用 msgType 的最高位标记负载的编码格式：置位为 MessagePack，未置位为 JSON
+// 开启压缩时次高位标记负载经过 gzip 压缩
+// 其余协议不占用 msgType 的任何位，业务可用的范围见 MaxMsgType
+const (
+	msgTypeFlagMsgPack    byte = 0x80
+	msgTypeFlagCompressed byte = 0x40
+)
+
+// 解压后负载的最大长度，防止恶意的压缩数据耗尽内存
+const maxDecompressedSize = 16 << 20
+
+// 协议保留的心跳消息类型，业务消息不可使用
+// Hub 收到心跳包时只刷新连接的活跃时间，不会转发到 MessageChan
+const PingMsgType byte = 0x7F
+
+// 生成心跳数据包：仅包含元数据，不带负载与签名
+func EncodePing() []byte {
+	out := []byte{PingMsgType, protocolVersion}
+	out = binary.BigEndian.AppendUint32(out, 0)
+	out = binary.BigEndian.AppendUint64(out, uint64(time.Since(protocolStartTime).Milliseconds()))
+	return out
+}
+
+// 是否是心跳数据包，应在 DecodeReq 之前判断，心跳包没有签名
+func IsPing(data []byte) bool {
+	return len(data) >= metaLength && data[0] == PingMsgType && data[1] == protocolVersion
+}
+
 type PacketProtocol struct {
 	signer    Signer
 	cryptor   Cryptor
 	marshaler PayloadMarshaler
+	auto      bool // 是否根据 msgType 中的格式标记自动选择解码器
+
+	compressThreshold int // 序列化后的负载达到该长度时压缩，<=0 不压缩
+	replayGuard       *replayGuard
 }
 
-func NewMsgPackProtocol(signer Signer, cryptor Cryptor) *PacketProtocol {
-	return &PacketProtocol{
-		signer:    signer,
-		cryptor:   cryptor,
-		marshaler: msgpackMarshaler,
+// PacketProtocol 的可选配置
+type PacketProtocolOption func(*PacketProtocol)
+
+// 序列化后的负载不小于 threshold 字节时使用 gzip 压缩，并在 msgType 中写入压缩标记
+// 小消息压缩的收益抵不过开销，保持不压缩。压缩标记占用 msgType 的次高位，
+// 解码端同样需要开启压缩（threshold 只影响编码）才会识别该标记，业务可用的 msgType 缩小为 0~62
+func WithCompression(threshold int) PacketProtocolOption {
+	return func(p *PacketProtocol) {
+		p.compressThreshold = threshold
 	}
 }
 
-func NewJsonProtocol(signer Signer, cryptor Cryptor) *PacketProtocol {
-	return &PacketProtocol{
+// 使用指定的序列化器、签名器、加密器创建协议，signer 与 cryptor 可以为 nil
+func NewPacketProtocol(marshaler PayloadMarshaler, signer Signer, cryptor Cryptor, opts ...PacketProtocolOption) *PacketProtocol {
+	p := &PacketProtocol{
 		signer:    signer,
 		cryptor:   cryptor,
-		marshaler: jsonMarshaler,
+		marshaler: marshaler,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+func NewMsgPackProtocol(signer Signer, cryptor Cryptor, opts ...PacketProtocolOption) *PacketProtocol {
+	return NewPacketProtocol(msgpackMarshaler, signer, cryptor, opts...)
+}
+
+func NewJsonProtocol(signer Signer, cryptor Cryptor, opts ...PacketProtocolOption) *PacketProtocol {
+	return NewPacketProtocol(jsonMarshaler, signer, cryptor, opts...)
+}
+
+// 负载为 Protobuf 消息的协议，解码需使用 DecodeReqInto / DecodeRespInto
+func NewProtobufProtocol(signer Signer, cryptor Cryptor, opts ...PacketProtocolOption) *PacketProtocol {
+	return NewPacketProtocol(protobufMarshaler, signer, cryptor, opts...)
+}
+
+// 自动识别负载格式的协议，用于同时存在 JSON 与 MessagePack 客户端的场景
+// 编码时使用 MessagePack 并在 msgType 中写入格式标记；解码时根据该标记选择解码器，
+// 未带标记的旧客户端数据按 JSON 解码
+func NewAutoProtocol(signer Signer, cryptor Cryptor, opts ...PacketProtocolOption) *PacketProtocol {
+	p := NewPacketProtocol(msgpackMarshaler, signer, cryptor, opts...)
+	p.auto = true
+	return p
+}
+
+func (m *PacketProtocol) compressionEnabled() bool { return m.compressThreshold > 0 }
+
+// 当前配置下 msgType 中被标记占用的位
+func (m *PacketProtocol) flagBits() byte {
+	var bits byte
+	if m.auto {
+		bits |= msgTypeFlagMsgPack
 	}
+	if m.compressionEnabled() {
+		bits |= msgTypeFlagCompressed
+	}
+	return bits
+}
+
+// 业务可用的最大 msgType：默认为 255，自动识别格式时为 126，开启压缩时为 62（63 加上压缩标记后与 PingMsgType 相同）
+// PingMsgType 始终保留，不可使用
+func (m *PacketProtocol) MaxMsgType() byte {
+	switch {
+	case m.compressionEnabled():
+		return 0x3E
+	case m.auto:
+		return PingMsgType - 1
+	}
+	return 0xFF
+}
+
+// 超出范围的 msgType 返回 ErrInvalidMsgType，避免被标记位截断后变成另一种消息
+func (m *PacketProtocol) encodeMsgType(msgType int32) (byte, error) {
+	if msgType < 0 || msgType > int32(m.MaxMsgType()) || msgType == int32(PingMsgType) {
+		return 0, fmt.Errorf("%w: %d", ErrInvalidMsgType, msgType)
+	}
+	raw := byte(msgType)
+	if m.auto {
+		raw |= msgTypeFlagMsgPack
+	}
+	return raw, nil
+}
+
+func (m *PacketProtocol) payloadMarshaler(rawMsgType byte) PayloadMarshaler {
+	if !m.auto {
+		return m.marshaler
+	}
+	if rawMsgType&msgTypeFlagMsgPack != 0 {
+		return msgpackMarshaler
+	}
+	return jsonMarshaler
 }
 
 func (m *PacketProtocol) GetMeta(data []byte) (*PacketMetaData, error) {
 	if len(data) < metaLength {
 		return nil, errors.New("bad data format")
 	}
-	requestId := int64(data[1])<<24 | int64(data[2])<<16 | int64(data[3])<<8 | int64(data[4])
-	ts := int64(data[1])<<24 | int64(data[2])<<16 | int64(data[3])<<8 | int64(data[4])
-	return &PacketMetaData{data[0], int32(requestId), int32(ts)}, nil
+	if data[1] != protocolVersion {
+		return nil, ErrProtocolVersion
+	}
+	requestId := int32(binary.BigEndian.Uint32(data[2:6]))
+	ts := int64(binary.BigEndian.Uint64(data[6:metaLength]))
+	msgType := data[0]
+	if msgType != PingMsgType {
+		msgType &^= m.flagBits()
+	}
+	return &PacketMetaData{msgType, requestId, ts}, nil
 }
 
 func (m *PacketProtocol) EncodeResp(msgType, requestId int32, code byte, payload any) ([]byte, error) {
+	return m.encode(msgType, requestId, true, code, payload)
+}
+
+// 生成请求数据包，供 Go 客户端使用，与 DecodeReq 对应
+func (m *PacketProtocol) EncodeReq(msgType, requestId int32, payload any) ([]byte, error) {
+	return m.encode(msgType, requestId, false, 0, payload)
+}
+
+// 生成请求数据包并以长度前缀帧写入 fw，用于 TCP 等流式连接，与 DecodeReqFrom 对应
+// 非并发安全，多个协程写入同一连接时需自行加锁
+func (m *PacketProtocol) EncodeReqTo(fw *FrameWriter, msgType, requestId int32, payload any) error {
+	data, err := m.EncodeReq(msgType, requestId, payload)
+	if err != nil {
+		return err
+	}
+	return fw.WriteFrame(data)
+}
+
+// 从 fr 中读取恰好一帧请求数据包并解析，负载反序列化到 payload 中
+// 同一连接应始终使用同一个 FrameReader，以便读取连续的帧
+// 帧在中途断开时返回 io.ErrUnexpectedEOF，帧超过 fr 的最大长度时返回 ErrFrameTooLarge
+func (m *PacketProtocol) DecodeReqFrom(fr *FrameReader, payload any) (*PacketMetaData, error) {
+	data, err := fr.ReadFrame()
+	if err != nil {
+		return nil, err
+	}
+	return m.DecodeReqInto(data, payload)
+}
+
+// 请求与响应的区别仅在于响应的元数据后多了1字节的 code
+func (m *PacketProtocol) encode(msgType, requestId int32, withCode bool, code byte, payload any) ([]byte, error) {
+	rawMsgType, err := m.encodeMsgType(msgType)
+	if err != nil {
+		return nil, err
+	}
+
 	var body []byte
 	if payload != nil {
-		var err error
 		body, err = m.marshaler.Marshal(payload)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	timestamp := int32(time.Since(protocolStartTime).Seconds())
-	out := []byte{byte(msgType)}
-	out = append(out, byte(requestId>>24&0x000F), byte(requestId>>16&0x000F), byte(requestId>>8&0x000F), byte(requestId&0x000F))
-	out = append(out, byte(timestamp>>24&0x000F), byte(timestamp>>16&0x000F), byte(timestamp>>8&0x000F), byte(timestamp&0x000F))
+	if m.compressionEnabled() && len(body) >= m.compressThreshold {
+		body, err = compressPayload(body)
+		if err != nil {
+			return nil, err
+		}
+		rawMsgType |= msgTypeFlagCompressed
+	}
+
+	timestamp := time.Since(protocolStartTime).Milliseconds()
+	out := []byte{rawMsgType, protocolVersion}
+	out = binary.BigEndian.AppendUint32(out, uint32(requestId))
+	out = binary.BigEndian.AppendUint64(out, uint64(timestamp))
+	if withCode {
+		out = append(out, code)
+	}
 
+	// 先压缩，再加密，最后对 元数据+密文 签名（加密后的数据无法再压缩）
 	if len(body) > 0 && m.cryptor != nil {
-		body, err := m.cryptor.Encrypt(body)
+		body, err = m.cryptor.Encrypt(body)
 		if err != nil {
 			return nil, err
 		}
-		out = append(out, body...)
 	}
+	out = append(out, body...)
 
 	if m.signer != nil {
 		signature, err := m.signer.Sign(out)
@@ -94,36 +288,147 @@ func (m *PacketProtocol) DecodeReq(data []byte) (*RequestPacket, error) {
 	if err != nil {
 		return nil, err
 	}
-	body := data[metaLength:]
+
+	payload, err := m.decodeBody(data, metaLength, meta)
+	if err != nil {
+		return nil, err
+	}
+	return &RequestPacket{*meta, payload}, nil
+}
+
+// 与 DecodeReq 相同，但将负载反序列化到 payload 中，payload 需为指针
+// 负载为空时 payload 保持不变
+func (m *PacketProtocol) DecodeReqInto(data []byte, payload any) (*PacketMetaData, error) {
+	meta, err := m.GetMeta(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.decodeBodyInto(data, metaLength, meta, payload); err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+// 解析 EncodeResp 生成的响应数据包，供 Go 客户端使用
+func (m *PacketProtocol) DecodeResp(data []byte) (*ResponsePacket, error) {
+	if len(data) < responseMetaLength {
+		return nil, errors.New("bad data format")
+	}
+	meta, err := m.GetMeta(data)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := m.decodeBody(data, responseMetaLength, meta)
+	if err != nil {
+		return nil, err
+	}
+	return &ResponsePacket{*meta, data[metaLength], payload}, nil
+}
+
+// 与 DecodeResp 相同，但将负载反序列化到 payload 中，返回的 ResponsePacket.Payload 为 nil
+func (m *PacketProtocol) DecodeRespInto(data []byte, payload any) (*ResponsePacket, error) {
+	if len(data) < responseMetaLength {
+		return nil, errors.New("bad data format")
+	}
+	meta, err := m.GetMeta(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.decodeBodyInto(data, responseMetaLength, meta, payload); err != nil {
+		return nil, err
+	}
+	return &ResponsePacket{PacketMetaData: *meta, Code: data[metaLength]}, nil
+}
+
+func (m *PacketProtocol) decodeBody(data []byte, headerLen int, meta *PacketMetaData) (any, error) {
+	var payload any
+	if err := m.decodeBodyInto(data, headerLen, meta, &payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// 按 防重放 -> 验签 -> 解密 -> 解压 -> 反序列化 的顺序解析 headerLen 之后的负载
+func (m *PacketProtocol) decodeBodyInto(data []byte, headerLen int, meta *PacketMetaData, payload any) error {
+	body := data[headerLen:]
+
+	if m.replayGuard != nil {
+		if err := m.replayGuard.checkFresh(meta); err != nil {
+			return err
+		}
+	}
 
 	if m.signer != nil {
 		signStart := len(data) - m.signer.SignatureLen()
-		if signStart >= len(data) || signStart < metaLength {
-			return nil, errors.New("bad data format: no sign")
+		if signStart >= len(data) || signStart < headerLen {
+			return errors.New("bad data format: no sign")
 		}
 		signature := data[signStart:]
-		body = data[metaLength:signStart]
+		body = data[headerLen:signStart]
 		dataToVerify := data[:signStart]
 		if !m.signer.Verify(dataToVerify, signature) {
-			return nil, errors.New("sign verify fail")
+			return errors.New("sign verify fail")
 		}
 	}
 
-	if len(body) > 0 {
-		if m.cryptor != nil {
-			body, err = m.cryptor.Decrypt(body)
-			if err != nil {
-				return nil, err
-			}
+	// 验签通过后才记录，伪造的数据包不会占用记录
+	if m.replayGuard != nil {
+		if err := m.replayGuard.checkSeen(meta, data); err != nil {
+			return err
 		}
+	}
 
-		var payload any
-		if err = m.marshaler.Unmarshal(body, &payload); err != nil {
-			return nil, err
+	if len(body) == 0 {
+		return nil
+	}
+
+	if m.cryptor != nil {
+		var err error
+		body, err = m.cryptor.Decrypt(body)
+		if err != nil {
+			return err
+		}
+	}
+
+	if m.compressionEnabled() && data[0]&msgTypeFlagCompressed != 0 {
+		var err error
+		body, err = decompressPayload(body)
+		if err != nil {
+			return err
 		}
+	}
+
+	return m.payloadMarshaler(data[0]).Unmarshal(body, payload)
+}
+
+func compressPayload(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(body); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
 
-		return &RequestPacket{*meta, payload}, nil
+func decompressPayload(body []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
 	}
+	defer r.Close()
 
-	return &RequestPacket{*meta, nil}, nil
+	out, err := io.ReadAll(io.LimitReader(r, maxDecompressedSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(out) > maxDecompressedSize {
+		return nil, ErrPayloadTooLarge
+	}
+	return out, nil
 }
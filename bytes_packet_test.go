@@ -0,0 +1,353 @@
+package niu
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPacketProtocolMsgTypeRange(t *testing.T) {
+	tests := []struct {
+		name     string
+		protocol *PacketProtocol
+		msgType  int32
+		wantErr  bool
+	}{
+		{"json high bit", NewJsonProtocol(nil, nil), 200, false},
+		{"msgpack max", NewMsgPackProtocol(nil, nil), 255, false},
+		{"msgpack overflow", NewMsgPackProtocol(nil, nil), 256, true},
+		{"auto max", NewAutoProtocol(nil, nil), 126, false},
+		{"auto overflow", NewAutoProtocol(nil, nil), 128, true},
+		{"json ping reserved", NewJsonProtocol(nil, nil), int32(PingMsgType), true},
+		{"negative", NewJsonProtocol(nil, nil), -1, true},
+		{"compression max", NewJsonProtocol(nil, nil, WithCompression(1)), 62, false},
+		{"compression ping collision", NewJsonProtocol(nil, nil, WithCompression(1)), 63, true},
+		{"compression 100", NewMsgPackProtocol(nil, nil, WithCompression(1)), 100, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := tt.protocol.EncodeResp(tt.msgType, 1, 0, "x")
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidMsgType) {
+					t.Fatalf("want ErrInvalidMsgType, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := tt.protocol.DecodeResp(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if int32(resp.MsgType) != tt.msgType {
+				t.Fatalf("msgType = %d, want %d", resp.MsgType, tt.msgType)
+			}
+			if IsPing(data) {
+				t.Fatal("business message detected as ping")
+			}
+		})
+	}
+}
+
+func TestAutoProtocolDetectsFormat(t *testing.T) {
+	auto := NewAutoProtocol(nil, nil)
+	tests := []struct {
+		name    string
+		encoder *PacketProtocol
+	}{
+		{"json client", NewJsonProtocol(nil, nil)},
+		{"msgpack via auto", auto},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := tt.encoder.EncodeReq(5, 9, map[string]any{"k": "v"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out map[string]string
+			meta, err := auto.DecodeReqInto(data, &out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if meta.MsgType != 5 || out["k"] != "v" {
+				t.Fatalf("got msgType %d, payload %v", meta.MsgType, out)
+			}
+		})
+	}
+}
+
+func TestPing(t *testing.T) {
+	ping := EncodePing()
+	if !IsPing(ping) {
+		t.Fatal("EncodePing not detected")
+	}
+	for _, p := range []*PacketProtocol{
+		NewJsonProtocol(nil, nil),
+		NewAutoProtocol(nil, nil),
+		NewMsgPackProtocol(nil, nil, WithCompression(1)),
+	} {
+		meta, err := p.GetMeta(ping)
+		if err != nil || meta.MsgType != PingMsgType {
+			t.Fatalf("GetMeta(ping) = %v, %v", meta, err)
+		}
+	}
+}
+
+func TestPacketProtocolCompression(t *testing.T) {
+	signer := NewHmacSigner([]byte("secret"))
+	p := NewMsgPackProtocol(signer, nil, WithCompression(256))
+	tests := []struct {
+		name           string
+		payload        string
+		wantCompressed bool
+	}{
+		{"small", "hello", false},
+		{"large", strings.Repeat("state", 1000), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := p.EncodeReq(7, 1, tt.payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := data[0]&msgTypeFlagCompressed != 0; got != tt.wantCompressed {
+				t.Fatalf("compressed = %v, want %v", got, tt.wantCompressed)
+			}
+			if tt.wantCompressed && len(data) >= len(tt.payload) {
+				t.Fatalf("compressed packet %d bytes, payload %d bytes", len(data), len(tt.payload))
+			}
+			var out string
+			meta, err := p.DecodeReqInto(data, &out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if meta.MsgType != 7 || out != tt.payload {
+				t.Fatalf("got msgType %d, payload len %d", meta.MsgType, len(out))
+			}
+		})
+	}
+}
+
+func TestDecompressPayloadLimit(t *testing.T) {
+	body, err := compressPayload(bytes.Repeat([]byte{0}, maxDecompressedSize+1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := decompressPayload(body); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Fatalf("want ErrPayloadTooLarge, got %v", err)
+	}
+}
+
+func BenchmarkPacketProtocolCompression(b *testing.B) {
+	payload := map[string]any{"players": strings.Split(strings.Repeat("player-state,", 500), ",")}
+	for _, bc := range []struct {
+		name string
+		p    *PacketProtocol
+	}{
+		{"plain", NewMsgPackProtocol(nil, nil)},
+		{"gzip", NewMsgPackProtocol(nil, nil, WithCompression(512))},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			var size int
+			for range b.N {
+				data, err := bc.p.EncodeResp(1, 1, 0, payload)
+				if err != nil {
+					b.Fatal(err)
+				}
+				size = len(data)
+			}
+			b.ReportMetric(float64(size), "bytes/packet")
+		})
+	}
+}
+
+func TestPacketProtocolRequestIdRoundTrip(t *testing.T) {
+	protocol := NewJsonProtocol(NewHmacSigner([]byte("secret")), nil)
+	tests := []int32{0, 1, 0xFF, 0x100, 0xFFFF, 0x10000, 0xFFFFFF, 0x1000000, math.MaxInt32, -1, math.MinInt32}
+	for _, requestId := range tests {
+		req, err := protocol.EncodeReq(1, requestId, "x")
+		if err != nil {
+			t.Fatal(err)
+		}
+		decodedReq, err := protocol.DecodeReq(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decodedReq.RequestId != requestId {
+			t.Errorf("req requestId = %d, want %d", decodedReq.RequestId, requestId)
+		}
+
+		resp, err := protocol.EncodeResp(1, requestId, 0xFF, "x")
+		if err != nil {
+			t.Fatal(err)
+		}
+		decodedResp, err := protocol.DecodeResp(resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decodedResp.RequestId != requestId || decodedResp.Code != 0xFF {
+			t.Errorf("resp requestId = %d code = %d, want %d 255", decodedResp.RequestId, decodedResp.Code, requestId)
+		}
+	}
+}
+
+func TestPacketMetaTimestamp(t *testing.T) {
+	protocol := NewJsonProtocol(nil, nil)
+	before := time.Now()
+	data, err := protocol.EncodeReq(1, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, err := protocol.GetMeta(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent := meta.Time(); sent.Before(before.Truncate(time.Millisecond)) || sent.After(time.Now()) {
+		t.Fatalf("Time() = %v, want around %v", sent, before)
+	}
+
+	// 手工构造边界时间戳的数据包头
+	tests := []struct {
+		ts   int64
+		want time.Time
+	}{
+		{0, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{1, time.Date(2025, 1, 1, 0, 0, 0, int(time.Millisecond), time.UTC)},
+		{86400000, time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{math.MaxUint32 + 1, protocolStartTime.Add((math.MaxUint32 + 1) * time.Millisecond)},
+		{-1, time.Date(2024, 12, 31, 23, 59, 59, int(999*time.Millisecond), time.UTC)},
+	}
+	for _, tt := range tests {
+		header := []byte{1, protocolVersion}
+		header = binary.BigEndian.AppendUint32(header, 7)
+		header = binary.BigEndian.AppendUint64(header, uint64(tt.ts))
+		meta, err := protocol.GetMeta(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if meta.Timestamp != tt.ts || !meta.Time().Equal(tt.want) {
+			t.Errorf("ts %d: got %d %v, want %v", tt.ts, meta.Timestamp, meta.Time(), tt.want)
+		}
+	}
+}
+
+func TestPacketProtocolBadHeader(t *testing.T) {
+	protocol := NewJsonProtocol(nil, nil)
+	data, _ := protocol.EncodeReq(1, 1, nil)
+	if _, err := protocol.GetMeta(data[:metaLength-1]); err == nil {
+		t.Fatal("short header should fail")
+	}
+	data[1] = protocolVersion - 1
+	if _, err := protocol.GetMeta(data); !errors.Is(err, ErrProtocolVersion) {
+		t.Fatalf("old version: %v, want ErrProtocolVersion", err)
+	}
+}
+
+func TestPacketTimeIsUTC(t *testing.T) {
+	if got := protocolStartTime.Unix(); got != 1735689600 {
+		t.Fatalf("protocolStartTime = %d, want 2025-01-01T00:00:00Z", got)
+	}
+
+	// 服务器时区不同也应得到相同的时间
+	local := time.Local
+	time.Local = time.FixedZone("UTC+8", 8*3600)
+	defer func() { time.Local = local }()
+
+	protocol := NewJsonProtocol(nil, nil)
+	before := time.Now().Truncate(time.Millisecond)
+	data, err := protocol.EncodeReq(1, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, err := protocol.GetMeta(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent := meta.Time()
+	if sent.Location() != time.UTC {
+		t.Fatalf("Time() location = %v, want UTC", sent.Location())
+	}
+	if sent.Before(before) || sent.After(time.Now()) {
+		t.Fatalf("Time() = %v, want around %v", sent, before)
+	}
+}
+
+func TestPacketProtocolStreamRoundTrip(t *testing.T) {
+	protocol := NewJsonProtocol(NewHmacSigner([]byte("secret")), nil)
+	client, server := net.Pipe()
+	defer server.Close()
+
+	go func() {
+		defer client.Close()
+		fw := NewFrameWriter(client, 0)
+		for i := range int32(3) {
+			if err := protocol.EncodeReqTo(fw, 1, i, map[string]int32{"n": i}); err != nil {
+				return
+			}
+		}
+		// 最后一帧在中途断开
+		client.Write(binary.BigEndian.AppendUint32(nil, 100))
+	}()
+
+	// 连续的帧使用同一个 FrameReader 读取
+	fr := NewFrameReader(server, 0)
+	for i := range int32(3) {
+		var payload map[string]int32
+		meta, err := protocol.DecodeReqFrom(fr, &payload)
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		if meta.RequestId != i || payload["n"] != i {
+			t.Fatalf("frame %d: requestId %d payload %v", i, meta.RequestId, payload)
+		}
+	}
+	var payload map[string]int32
+	if _, err := protocol.DecodeReqFrom(fr, &payload); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("truncated frame: %v, want io.ErrUnexpectedEOF", err)
+	}
+}
+
+func TestPacketProtocolReplayWindow(t *testing.T) {
+	protocol := NewJsonProtocol(NewHmacSigner([]byte("secret")), nil, WithReplayWindow(time.Minute))
+	data, err := protocol.EncodeReq(1, 1, "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := protocol.DecodeReq(data); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := protocol.DecodeReq(data); !errors.Is(err, ErrReplayedPacket) {
+		t.Fatalf("replayed packet: %v, want ErrReplayedPacket", err)
+	}
+
+	// 相同 requestId 的其他数据包不算重放
+	next, err := protocol.EncodeReq(1, 1, "y")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := protocol.DecodeReq(next); err != nil {
+		t.Fatalf("new packet: %v", err)
+	}
+}
+
+func TestPacketProtocolReplayWindowStale(t *testing.T) {
+	protocol := NewJsonProtocol(nil, nil, WithReplayWindow(time.Minute))
+	now := time.Since(protocolStartTime).Milliseconds()
+	for _, ts := range []int64{now - 2*time.Minute.Milliseconds(), now + 2*time.Minute.Milliseconds()} {
+		data, err := protocol.EncodeReq(1, 1, "x")
+		if err != nil {
+			t.Fatal(err)
+		}
+		binary.BigEndian.PutUint64(data[6:], uint64(ts))
+		if _, err := protocol.DecodeReq(data); !errors.Is(err, ErrStalePacket) {
+			t.Errorf("ts %d: %v, want ErrStalePacket", ts-now, err)
+		}
+	}
+}
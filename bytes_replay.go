@@ -0,0 +1,83 @@
+package niu
+
+import (
+	"errors"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+var (
+	ErrStalePacket    = errors.New("packet timestamp outside replay window")
+	ErrReplayedPacket = errors.New("packet replayed")
+)
+
+// 开启防重放：时间戳与当前时间相差超过 window 的数据包，以及 window 内已经收到过的数据包都会被拒绝
+// 只有配置了 signer 时才有意义，否则攻击者可以任意修改时间戳
+// 已收到的数据包记录在内存中，多节点部署时只能防止同一节点上的重放
+func WithReplayWindow(window time.Duration) PacketProtocolOption {
+	return func(p *PacketProtocol) {
+		if window > 0 {
+			p.replayGuard = newReplayGuard(window)
+		}
+	}
+}
+
+// 同一客户端的 requestId 与时间戳不会重复，但不同客户端可能相同，因此加上整个数据包的哈希
+type replayKey struct {
+	requestId int32
+	timestamp int64
+	sum       uint64
+}
+
+type replayGuard struct {
+	window time.Duration
+
+	mutex     sync.Mutex
+	seen      map[replayKey]time.Time // value 为过期时间
+	lastPrune time.Time
+}
+
+func newReplayGuard(window time.Duration) *replayGuard {
+	return &replayGuard{
+		window:    window,
+		seen:      make(map[replayKey]time.Time),
+		lastPrune: time.Now(),
+	}
+}
+
+// 在解析负载前检查时间戳，避免为过期的数据包验签
+func (g *replayGuard) checkFresh(meta *PacketMetaData) error {
+	d := time.Since(meta.Time())
+	if d > g.window || d < -g.window {
+		return ErrStalePacket
+	}
+	return nil
+}
+
+// 验签通过后记录数据包，已记录过的返回 ErrReplayedPacket
+func (g *replayGuard) checkSeen(meta *PacketMetaData, data []byte) error {
+	h := fnv.New64a()
+	h.Write(data)
+	key := replayKey{meta.RequestId, meta.Timestamp, h.Sum64()}
+
+	now := time.Now()
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if now.Sub(g.lastPrune) > g.window {
+		for k, expireAt := range g.seen {
+			if now.After(expireAt) {
+				delete(g.seen, k)
+			}
+		}
+		g.lastPrune = now
+	}
+
+	if expireAt, ok := g.seen[key]; ok && now.Before(expireAt) {
+		return ErrReplayedPacket
+	}
+	// 时间戳超出窗口后会被 checkFresh 拒绝，记录只需保留到那时
+	g.seen[key] = meta.Time().Add(g.window)
+	return nil
+}
@@ -9,9 +9,16 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
+var (
+	ErrKeyNoExpiry = errors.New("key has no expiry")
+	ErrKeyNotExist = errors.New("key does not exist")
+)
+
 type Cache struct {
 	master *redis.Client
 	slave  *redis.Client
+
+	jsonCompressThreshold int // SetJsonCompressed 开始压缩的 JSON 长度
 }
 
 // 初始化缓存
@@ -22,7 +29,7 @@ func NewCache(ctx context.Context, masterOpt, slaveOpt *redis.Options) (*Cache,
 	if err != nil {
 		return nil, err
 	}
-	c := &Cache{masterDb, masterDb}
+	c := &Cache{master: masterDb, slave: masterDb, jsonCompressThreshold: defaultJsonCompressThreshold}
 	if slaveOpt != nil {
 		slaveDb := redis.NewClient(slaveOpt)
 		_, err := slaveDb.Ping(ctx).Result()
@@ -52,6 +59,14 @@ func (c *Cache) Slave() *redis.Client {
 	return c.slave
 }
 
+// 为主从实例注册命令钩子，可用于链路追踪、慢查询日志等
+func (c *Cache) AddHook(hook redis.Hook) {
+	c.master.AddHook(hook)
+	if c.slave != c.master {
+		c.slave.AddHook(hook)
+	}
+}
+
 func (c *Cache) Close() error {
 	if c.master != nil {
 		client := c.master
@@ -73,6 +88,8 @@ func (c *Cache) Close() error {
 	return nil
 }
 
+// 在事务 pipeline 中执行多个命令；迁移到 Redis Cluster 时所有 key 需位于同一槽位，可使用 HashTagKey 生成
+// Cache 只支持单机与主从实例，这里不检查槽位；迁移前可用 SameKeySlot 检查一批 key 是否位于同一槽位
 func (c *Cache) Batch(ctx context.Context, f func(pipe redis.Pipeliner)) (map[int]interface{}, map[int]error) {
 	pp := c.master.TxPipeline()
 	// defer pp.Discard()
@@ -175,6 +192,29 @@ func (c *Cache) KeyExists(ctx context.Context, keys ...string) (int64, error) {
 	return c.slave.Exists(ctx, keys...).Result()
 }
 
+// 使用 pipeline 一次查询多个 key 是否存在，KeyExists 只返回存在的个数
+func (c *Cache) MultiExists(ctx context.Context, keys ...string) (map[string]bool, error) {
+	out := make(map[string]bool, len(keys))
+	if len(keys) == 0 {
+		return out, nil
+	}
+
+	cmds := make([]*redis.IntCmd, len(keys))
+	_, err := c.slave.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = pipe.Exists(ctx, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i, key := range keys {
+		out[key] = cmds[i].Val() > 0
+	}
+	return out, nil
+}
+
 func (c *Cache) KeyExpire(ctx context.Context, key string, expiry time.Duration) (bool, error) {
 	return c.master.Expire(ctx, key, expiry).Result()
 }
@@ -183,6 +223,31 @@ func (c *Cache) KeyExpireAt(ctx context.Context, key string, expiry time.Time) (
 	return c.master.ExpireAt(ctx, key, expiry).Result()
 }
 
+// 获取 key 的剩余过期时间（秒级精度）
+// key 没有设置过期时间时返回 ErrKeyNoExpiry，key 不存在时返回 ErrKeyNotExist
+func (c *Cache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return ttlResult(c.slave.TTL(ctx, key).Result())
+}
+
+// 与 TTL 相同，但为毫秒级精度
+func (c *Cache) PTTL(ctx context.Context, key string) (time.Duration, error) {
+	return ttlResult(c.slave.PTTL(ctx, key).Result())
+}
+
+// go-redis 对 -1/-2 不做单位换算，直接返回 -1ns/-2ns
+func ttlResult(d time.Duration, err error) (time.Duration, error) {
+	if err != nil {
+		return 0, err
+	}
+	switch d {
+	case -1:
+		return 0, ErrKeyNoExpiry
+	case -2:
+		return 0, ErrKeyNotExist
+	}
+	return d, nil
+}
+
 func (c *Cache) DecrBy(ctx context.Context, key string, decrement int64) (int64, error) {
 	return c.master.DecrBy(ctx, key, decrement).Result()
 }
@@ -224,6 +289,34 @@ func (c *Cache) SetNX(ctx context.Context, key string, value any, expiry time.Du
 	return c.master.SetNX(ctx, key, value, expiry).Result()
 }
 
+func (c *Cache) SetNXJson(ctx context.Context, key string, val any, expiry time.Duration) (bool, error) {
+	jsonStr, err := json.Marshal(val)
+	if err != nil {
+		return false, err
+	}
+	return c.master.SetNX(ctx, key, string(jsonStr), expiry).Result()
+}
+
+// KEYS[1] key，ARGV[1] 期望的旧值，ARGV[2] 新值，ARGV[3] 过期时间(ms)，<=0 表示不过期
+var luaCompareAndSwap = redis.NewScript(`
+if redis.call("get", KEYS[1]) ~= ARGV[1] then return 0 end
+if tonumber(ARGV[3]) > 0 then
+	redis.call("set", KEYS[1], ARGV[2], "PX", ARGV[3])
+else
+	redis.call("set", KEYS[1], ARGV[2])
+end
+return 1`)
+
+// 仅当 key 的当前值等于 expected 时才设置为 newValue，返回是否设置成功
+// key 不存在时不会设置；expiry 与 Set 一致，为0时不过期
+func (c *Cache) CompareAndSwap(ctx context.Context, key, expected, newValue string, expiry time.Duration) (bool, error) {
+	n, err := luaCompareAndSwap.Run(ctx, c.master, []string{key}, expected, newValue, expiry.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
 func (c *Cache) GetSet(ctx context.Context, key string, value any) (string, error) {
 	return c.master.GetSet(ctx, key, value).Result()
 }
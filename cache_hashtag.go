@@ -0,0 +1,71 @@
+package niu
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrInvalidHashTag = errors.New("hash tag must be non-empty and must not contain '}'")
+
+// Redis Cluster 的槽位数
+const redisClusterSlots = 16384
+
+// 生成带 hash tag 的 key：{tag}:key，Redis Cluster 只根据 tag 计算槽位
+// 同一个 tag 的 key 位于同一槽位，可以在 MultiSet、MultiGet、Batch 等多 key 命令中一起使用
+// 如 HashTagKey("user:1", "profile") 与 HashTagKey("user:1", "settings")
+// tag 为空或包含 } 时 Redis 实际使用的 tag 与传入的不同，tag 来自外部输入时先用 ValidateHashTag 检查
+func HashTagKey(tag, key string) string {
+	return "{" + tag + "}:" + key
+}
+
+// 检查 tag 能否用于 HashTagKey，为空或包含 } 时返回 ErrInvalidHashTag
+func ValidateHashTag(tag string) error {
+	if tag == "" || strings.IndexByte(tag, '}') >= 0 {
+		return ErrInvalidHashTag
+	}
+	return nil
+}
+
+// 返回 Redis 计算槽位时实际使用的部分：第一个 { 与其后第一个 } 之间的内容非空时为该内容，否则为整个 key
+func KeyHashTag(key string) string {
+	start := strings.IndexByte(key, '{')
+	if start < 0 {
+		return key
+	}
+	end := strings.IndexByte(key[start+1:], '}')
+	if end <= 0 {
+		return key
+	}
+	return key[start+1 : start+1+end]
+}
+
+// 返回 key 在 Redis Cluster 中的槽位，与 CLUSTER KEYSLOT 的结果一致
+func KeySlot(key string) int {
+	return int(crc16([]byte(KeyHashTag(key))) % redisClusterSlots)
+}
+
+// 所有 key 是否位于同一槽位，keys 为空时返回 true
+func SameKeySlot(keys ...string) bool {
+	for i := 1; i < len(keys); i++ {
+		if KeySlot(keys[i]) != KeySlot(keys[0]) {
+			return false
+		}
+	}
+	return true
+}
+
+// CRC16-CCITT (XMODEM)，Redis Cluster 使用的校验算法
+func crc16(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for range 8 {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
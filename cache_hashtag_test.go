@@ -0,0 +1,69 @@
+package niu
+
+import (
+	"errors"
+	"testing"
+)
+
+// 期望值为 Redis CLUSTER KEYSLOT 的结果
+func TestKeySlot(t *testing.T) {
+	tests := []struct {
+		key  string
+		tag  string
+		slot int
+	}{
+		{"123456789", "123456789", 12739},
+		{"foo", "foo", 12182},
+		{"{user1000}.following", "user1000", 3443},
+		{"{user1000}.followers", "user1000", 3443},
+		{"foo{}{bar}", "foo{}{bar}", 8363},
+		{"foo{{bar}}zap", "{bar", 4015},
+		{"foo{bar}{zap}", "bar", 5061},
+		{"{bar", "{bar", 4015},
+		{"", "", 0},
+	}
+	for _, tt := range tests {
+		if got := KeyHashTag(tt.key); got != tt.tag {
+			t.Errorf("KeyHashTag(%q) = %q, want %q", tt.key, got, tt.tag)
+		}
+		if got := KeySlot(tt.key); got != tt.slot {
+			t.Errorf("KeySlot(%q) = %d, want %d", tt.key, got, tt.slot)
+		}
+	}
+}
+
+func TestHashTagKey(t *testing.T) {
+	tests := []struct {
+		tag, key string
+		want     string
+		err      error
+	}{
+		{"user:1", "profile", "{user:1}:profile", nil},
+		{"{a", "k", "{{a}:k", nil},
+		{"", "k", "{}:k", ErrInvalidHashTag},
+		{"a}b", "k", "{a}b}:k", ErrInvalidHashTag},
+		{"}", "k", "{}}:k", ErrInvalidHashTag},
+	}
+	for _, tt := range tests {
+		if err := ValidateHashTag(tt.tag); !errors.Is(err, tt.err) {
+			t.Errorf("ValidateHashTag(%q) = %v, want %v", tt.tag, err, tt.err)
+		}
+		got := HashTagKey(tt.tag, tt.key)
+		if got != tt.want {
+			t.Errorf("HashTagKey(%q, %q) = %q, want %q", tt.tag, tt.key, got, tt.want)
+		}
+		// 只有通过检查的 tag 才会被 Redis 原样用于计算槽位
+		if (KeyHashTag(got) == tt.tag) != (tt.err == nil) {
+			t.Errorf("KeyHashTag(%q) = %q, tag %q", got, KeyHashTag(got), tt.tag)
+		}
+	}
+
+	a := HashTagKey("user:1", "profile")
+	b := HashTagKey("user:1", "settings")
+	if !SameKeySlot(a, b) {
+		t.Fatal("keys with the same tag should share a slot")
+	}
+	if !SameKeySlot() || SameKeySlot("foo", "bar") {
+		t.Fatal("SameKeySlot edge cases")
+	}
+}
@@ -0,0 +1,135 @@
+package niu
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 使用 MGET 一次读取多个 SetJson 写入的值，结果与 keys 一一对应，不存在的 key 对应 nil
+// 任意一个值无法解析时返回错误，不会返回部分结果
+func MultiGetJson[T any](ctx context.Context, c *Cache, keys ...string) ([]*T, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	values, err := c.slave.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]*T, len(values))
+	for i, v := range values {
+		str, ok := v.(string)
+		if !ok {
+			continue
+		}
+		item := new(T)
+		if err := json.Unmarshal([]byte(str), item); err != nil {
+			return nil, fmt.Errorf("key %s: %w", keys[i], err)
+		}
+		out[i] = item
+	}
+	return out, nil
+}
+
+// 使用 HMGET 一次读取 hash 中多个 HSetJson 写入的字段，不存在的字段不会出现在结果中
+// 任意一个值无法解析时返回错误，不会返回部分结果
+func HMGetJson[T any](ctx context.Context, c *Cache, key string, fields ...string) (map[string]*T, error) {
+	out := make(map[string]*T, len(fields))
+	if len(fields) == 0 {
+		return out, nil
+	}
+
+	values, err := c.slave.HMGet(ctx, key, fields...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, v := range values {
+		str, ok := v.(string)
+		if !ok {
+			continue
+		}
+		item := new(T)
+		if err := json.Unmarshal([]byte(str), item); err != nil {
+			return nil, fmt.Errorf("field %s: %w", fields[i], err)
+		}
+		out[fields[i]] = item
+	}
+	return out, nil
+}
+
+// 使用一个 pipeline 批量写入 JSON 值，每个 key 的过期时间均为 expiry，用于服务启动时预热缓存
+// 写入前先序列化所有值，任意一个失败时不会写入；返回第一个失败的命令的错误，其余命令仍会执行
+func (c *Cache) PreloadJson(ctx context.Context, items map[string]any, expiry time.Duration) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	values := make(map[string][]byte, len(items))
+	for key, val := range items {
+		data, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Errorf("key %s: %w", key, err)
+		}
+		values[key] = data
+	}
+
+	_, err := c.master.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for key, data := range values {
+			pipe.Set(ctx, key, data, expiry)
+		}
+		return nil
+	})
+	return err
+}
+
+const defaultJsonCompressThreshold = 1024
+
+// 压缩后的值以该前缀开头，JSON 不会以 0 字节开头，直接用 GetJson 读取会解析失败而不是得到错误的数据
+var jsonCompressedMagic = []byte{0x00, 'g', 'z'}
+
+// 设置 SetJsonCompressed 开始压缩的 JSON 长度（字节），默认 1024，threshold <= 0 时总是压缩
+// 需在使用前设置，非并发安全
+func (c *Cache) SetJsonCompressThreshold(threshold int) {
+	c.jsonCompressThreshold = threshold
+}
+
+// 与 SetJson 相同，但 JSON 长度达到阈值时使用 gzip 压缩后存储，需使用 GetJsonCompressed 读取
+// 未达到阈值时存储原始 JSON，GetJson 同样可以读取
+func (c *Cache) SetJsonCompressed(ctx context.Context, key string, val any, expiry time.Duration) (string, error) {
+	data, err := json.Marshal(val)
+	if err != nil {
+		return "", err
+	}
+	if len(data) >= c.jsonCompressThreshold {
+		compressed, err := compressPayload(data)
+		if err != nil {
+			return "", err
+		}
+		data = append(bytes.Clone(jsonCompressedMagic), compressed...)
+	}
+	return c.master.Set(ctx, key, data, expiry).Result()
+}
+
+// 读取 SetJsonCompressed 写入的值，根据前缀判断是否需要解压，也可读取 SetJson 写入的值
+func GetJsonCompressed[T any](ctx context.Context, c *Cache, key string) (T, error) {
+	var out T
+	data, err := c.slave.Get(ctx, key).Bytes()
+	if err != nil {
+		return out, err
+	}
+	if bytes.HasPrefix(data, jsonCompressedMagic) {
+		data, err = decompressPayload(data[len(jsonCompressedMagic):])
+		if err != nil {
+			return out, err
+		}
+	}
+	err = json.Unmarshal(data, &out)
+	return out, err
+}
@@ -0,0 +1,160 @@
+package niu
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testItem struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestMultiGetJson(t *testing.T) {
+	ctx := context.Background()
+	c, mr := newTestCache(t)
+	mr.Set("a", `{"id":1,"name":"a"}`)
+	mr.Set("c", `{"id":3,"name":"c"}`)
+
+	items, err := MultiGetJson[testItem](ctx, c, "a", "missing", "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 3 || items[1] != nil {
+		t.Fatalf("items = %v, want 3 with a nil for the missing key", items)
+	}
+	if *items[0] != (testItem{1, "a"}) || *items[2] != (testItem{3, "c"}) {
+		t.Fatalf("got %+v %+v", *items[0], *items[2])
+	}
+
+	if items, err := MultiGetJson[testItem](ctx, c); err != nil || items != nil {
+		t.Fatalf("no keys: %v %v", items, err)
+	}
+
+	mr.Set("bad", "not json")
+	items, err = MultiGetJson[testItem](ctx, c, "a", "bad")
+	if err == nil || !strings.Contains(err.Error(), "key bad") || items != nil {
+		t.Fatalf("malformed value: %v %v, want an error naming the key and no partial result", items, err)
+	}
+}
+
+func TestHMGetJson(t *testing.T) {
+	ctx := context.Background()
+	c, mr := newTestCache(t)
+	mr.HSet("h", "a", `{"id":1,"name":"a"}`, "b", `{"id":2,"name":"b"}`)
+
+	got, err := HMGetJson[testItem](ctx, c, "h", "a", "missing", "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got["missing"]; ok || len(got) != 2 {
+		t.Fatalf("got %v, want only the existing fields", got)
+	}
+	if *got["a"] != (testItem{1, "a"}) || *got["b"] != (testItem{2, "b"}) {
+		t.Fatalf("got %+v %+v", *got["a"], *got["b"])
+	}
+
+	if got, err := HMGetJson[testItem](ctx, c, "missing", "a"); err != nil || len(got) != 0 {
+		t.Fatalf("missing key: %v %v, want an empty map", got, err)
+	}
+	if got, err := HMGetJson[testItem](ctx, c, "h"); err != nil || got == nil || len(got) != 0 {
+		t.Fatalf("no fields: %v %v, want an empty map", got, err)
+	}
+
+	mr.HSet("h", "bad", "{")
+	if got, err := HMGetJson[testItem](ctx, c, "h", "a", "bad"); err == nil || !strings.Contains(err.Error(), "field bad") || got != nil {
+		t.Fatalf("malformed field: %v %v", got, err)
+	}
+}
+
+func TestJsonCompressed(t *testing.T) {
+	ctx := context.Background()
+	c, mr := newTestCache(t)
+	c.SetJsonCompressThreshold(20)
+
+	// 字符串的 JSON 比原串多两个引号
+	below := strings.Repeat("a", 17)
+	at := strings.Repeat("b", 18)
+	if _, err := c.SetJsonCompressed(ctx, "below", below, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SetJsonCompressed(ctx, "at", at, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := mr.Get("below"); v != `"`+below+`"` {
+		t.Fatalf("below threshold stored %q, want raw JSON", v)
+	}
+	if v, _ := mr.Get("at"); !strings.HasPrefix(v, string(jsonCompressedMagic)) {
+		t.Fatalf("at threshold stored %q, want compressed", v)
+	}
+	if mr.TTL("at") != time.Minute {
+		t.Fatalf("ttl = %v", mr.TTL("at"))
+	}
+
+	for key, want := range map[string]string{"below": below, "at": at} {
+		got, err := GetJsonCompressed[string](ctx, c, key)
+		if err != nil || got != want {
+			t.Fatalf("GetJsonCompressed(%s) = %q %v", key, got, err)
+		}
+	}
+
+	// 未压缩的值 GetJson 可以读取，压缩的值会解析失败
+	var s string
+	if err := c.GetJson(ctx, "below", &s); err != nil || s != below {
+		t.Fatalf("GetJson(below) = %q %v", s, err)
+	}
+	if err := c.GetJson(ctx, "at", &s); err == nil {
+		t.Fatal("GetJson should fail on a compressed value")
+	}
+
+	// 大的值压缩后更小
+	large := testItem{1, strings.Repeat("x", 4096)}
+	if _, err := c.SetJsonCompressed(ctx, "large", large, 0); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := mr.Get("large"); len(v) >= 4096 {
+		t.Fatalf("compressed size %d", len(v))
+	}
+	if got, err := GetJsonCompressed[testItem](ctx, c, "large"); err != nil || got != large {
+		t.Fatalf("large round trip: %v", err)
+	}
+}
+
+func TestPreloadJson(t *testing.T) {
+	ctx := context.Background()
+	c, mr := newTestCache(t)
+	items := make(map[string]any, 500)
+	for i := range 500 {
+		items["item:"+strconv.Itoa(i)] = testItem{i, "n" + strconv.Itoa(i)}
+	}
+	if err := c.PreloadJson(ctx, items, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if keys := mr.Keys(); len(keys) != 500 {
+		t.Fatalf("%d keys, want 500", len(keys))
+	}
+	for i := range 500 {
+		key := "item:" + strconv.Itoa(i)
+		var got testItem
+		if err := c.GetJson(ctx, key, &got); err != nil || got != (testItem{i, "n" + strconv.Itoa(i)}) {
+			t.Fatalf("%s = %+v %v", key, got, err)
+		}
+		if ttl := mr.TTL(key); ttl != time.Hour {
+			t.Fatalf("%s ttl = %v, want 1h", key, ttl)
+		}
+	}
+
+	// 任意一个值无法序列化时不写入
+	mr.FlushAll()
+	bad := map[string]any{"ok": 1, "bad": make(chan int)}
+	if err := c.PreloadJson(ctx, bad, time.Hour); err == nil {
+		t.Fatal("unmarshalable value should fail")
+	}
+	if keys := mr.Keys(); len(keys) != 0 {
+		t.Fatalf("wrote %v after a marshal error", keys)
+	}
+}
@@ -0,0 +1,185 @@
+package niu
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 二级缓存：进程内的有界 LRU（短 TTL） + Redis
+// 适用于访问频繁、很少变化的 key。通过 L2Cache 修改数据时，会经由 Redis 发布订阅
+// 通知所有节点删除本地副本；绕过 L2Cache 直接修改 Redis 时，本地副本最多在 localTtl 后过期
+type L2Cache struct {
+	cache      *Cache
+	channel    string
+	localTtl   time.Duration
+	maxEntries int
+
+	mutex   sync.Mutex // 读取时需要检查过期并删除，因此不使用 SyncLRU
+	local   *LRU[string, l2CacheEntry]
+	loading map[string]*l2Loading // 正在从 Redis 读取的 key
+
+	pubsub *redis.PubSub
+	done   chan Empty
+}
+
+type l2CacheEntry struct {
+	value    string
+	expireAt time.Time
+}
+
+// 读取 Redis 期间该 key 的失效次数，读取前后不一致说明读到的值可能已过时，不写入本地
+type l2Loading struct {
+	refs int
+	gen  uint64
+}
+
+// channel 为用于广播失效消息的频道，同一组节点需使用相同的频道
+// maxEntries 为本地最多缓存的 key 数量，localTtl 为本地副本的有效期
+func NewL2Cache(ctx context.Context, cache *Cache, channel string, maxEntries int, localTtl time.Duration) (*L2Cache, error) {
+	pubsub := cache.master.Subscribe(ctx, channel)
+	// 等待订阅成功，确保不会漏掉之后的失效消息
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+
+	l := &L2Cache{
+		cache:      cache,
+		channel:    channel,
+		localTtl:   localTtl,
+		maxEntries: maxEntries,
+		local:      NewLRU[string, l2CacheEntry](maxEntries, nil),
+		loading:    make(map[string]*l2Loading),
+		pubsub:     pubsub,
+		done:       make(chan Empty),
+	}
+	go func() {
+		defer close(l.done)
+		for msg := range pubsub.Channel() {
+			l.evictLocal(msg.Payload)
+		}
+	}()
+	return l, nil
+}
+
+func (l *L2Cache) Close() error {
+	err := l.pubsub.Close()
+	<-l.done
+	return err
+}
+
+func (l *L2Cache) Get(ctx context.Context, key string) (string, error) {
+	if v, ok := l.getLocal(key); ok {
+		return v, nil
+	}
+
+	loading, gen := l.beginLoad(key)
+	v, err := l.cache.Get(ctx, key)
+	if err != nil {
+		l.finishLoad(key, loading, gen, "", false)
+		return "", err
+	}
+	l.finishLoad(key, loading, gen, v, true)
+	return v, nil
+}
+
+func (l *L2Cache) GetJson(ctx context.Context, key string, out any) error {
+	jsonStr, err := l.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal([]byte(jsonStr), out)
+}
+
+func (l *L2Cache) Set(ctx context.Context, key string, value any, expiry time.Duration) (string, error) {
+	res, err := l.cache.Set(ctx, key, value, expiry)
+	if err != nil {
+		return res, err
+	}
+	return res, l.Invalidate(ctx, key)
+}
+
+func (l *L2Cache) SetJson(ctx context.Context, key string, val any, expiry time.Duration) (string, error) {
+	res, err := l.cache.SetJson(ctx, key, val, expiry)
+	if err != nil {
+		return res, err
+	}
+	return res, l.Invalidate(ctx, key)
+}
+
+func (l *L2Cache) KeyDel(ctx context.Context, keys ...string) (int64, error) {
+	n, err := l.cache.KeyDel(ctx, keys...)
+	if err != nil {
+		return n, err
+	}
+	return n, l.Invalidate(ctx, keys...)
+}
+
+// 删除本地副本，并通知其他节点删除
+func (l *L2Cache) Invalidate(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		l.evictLocal(key)
+		if err := l.cache.master.Publish(ctx, l.channel, key).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *L2Cache) getLocal(key string) (string, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	entry, ok := l.local.Get(key)
+	if !ok {
+		return "", false
+	}
+	if time.Now().After(entry.expireAt) {
+		l.local.Remove(key)
+		return "", false
+	}
+	return entry.value, true
+}
+
+// 读取 Redis 前记录该 key 的失效次数
+func (l *L2Cache) beginLoad(key string) (*l2Loading, uint64) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	loading, ok := l.loading[key]
+	if !ok {
+		loading = &l2Loading{}
+		l.loading[key] = loading
+	}
+	loading.refs++
+	return loading, loading.gen
+}
+
+// 读取期间没有失效时才写入本地副本
+func (l *L2Cache) finishLoad(key string, loading *l2Loading, gen uint64, value string, ok bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if ok && l.maxEntries > 0 && loading.gen == gen {
+		l.local.Put(key, l2CacheEntry{value, time.Now().Add(l.localTtl)})
+	}
+	loading.refs--
+	if loading.refs == 0 {
+		delete(l.loading, key)
+	}
+}
+
+func (l *L2Cache) evictLocal(key string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.local.Remove(key)
+	if loading, ok := l.loading[key]; ok {
+		loading.gen++
+	}
+}
@@ -0,0 +1,126 @@
+package niu
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func newTestCache(t *testing.T) (*Cache, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	c, err := NewCacheWithAddr(context.Background(), mr.Addr(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c, mr
+}
+
+func newTestL2Cache(t *testing.T, c *Cache, maxEntries int, localTtl time.Duration) *L2Cache {
+	t.Helper()
+	l, err := NewL2Cache(context.Background(), c, "l2:invalidate", maxEntries, localTtl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+func TestL2CacheLocalCopy(t *testing.T) {
+	ctx := context.Background()
+	c, mr := newTestCache(t)
+	l := newTestL2Cache(t, c, 10, time.Minute)
+
+	mr.Set("k", "v1")
+	if v, err := l.Get(ctx, "k"); err != nil || v != "v1" {
+		t.Fatalf("got %q %v", v, err)
+	}
+	// 绕过 L2Cache 修改时读到本地副本
+	mr.Set("k", "v2")
+	if v, _ := l.Get(ctx, "k"); v != "v1" {
+		t.Fatalf("want local copy v1, got %q", v)
+	}
+	if err := l.Invalidate(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := l.Get(ctx, "k"); v != "v2" {
+		t.Fatalf("want v2 after invalidate, got %q", v)
+	}
+}
+
+func TestL2CacheLocalTtl(t *testing.T) {
+	ctx := context.Background()
+	c, mr := newTestCache(t)
+	l := newTestL2Cache(t, c, 10, 20*time.Millisecond)
+
+	mr.Set("k", "v1")
+	l.Get(ctx, "k")
+	mr.Set("k", "v2")
+	time.Sleep(30 * time.Millisecond)
+	if v, _ := l.Get(ctx, "k"); v != "v2" {
+		t.Fatalf("want v2 after local ttl, got %q", v)
+	}
+}
+
+func TestL2CacheInvalidateOtherNodes(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestCache(t)
+	a := newTestL2Cache(t, c, 10, time.Minute)
+	b := newTestL2Cache(t, c, 10, time.Minute)
+
+	if _, err := a.Set(ctx, "k", "v1", 0); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := b.Get(ctx, "k"); v != "v1" {
+		t.Fatalf("got %q", v)
+	}
+	if _, err := a.Set(ctx, "k", "v2", 0); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool {
+		v, _ := b.Get(ctx, "k")
+		return v == "v2"
+	})
+}
+
+// Redis 读取与写入本地之间发生的失效不能被覆盖
+func TestL2CacheInvalidateDuringLoad(t *testing.T) {
+	c, _ := newTestCache(t)
+	l := newTestL2Cache(t, c, 10, time.Minute)
+
+	tests := []struct {
+		name       string
+		invalidate bool
+		wantCached bool
+		concurrent bool
+	}{
+		{"no invalidation", false, true, false},
+		{"invalidated during load", true, false, false},
+		{"invalidated during overlapping loads", true, false, true},
+	}
+	for _, tt := range tests {
+		l.evictLocal("k")
+		loading, gen := l.beginLoad("k")
+		var other *l2Loading
+		var otherGen uint64
+		if tt.concurrent {
+			other, otherGen = l.beginLoad("k")
+		}
+		if tt.invalidate {
+			l.evictLocal("k")
+		}
+		l.finishLoad("k", loading, gen, "stale", true)
+		if tt.concurrent {
+			l.finishLoad("k", other, otherGen, "stale", true)
+		}
+		if _, ok := l.getLocal("k"); ok != tt.wantCached {
+			t.Fatalf("%s: cached %v, want %v", tt.name, ok, tt.wantCached)
+		}
+		if len(l.loading) != 0 {
+			t.Fatalf("%s: loading not cleaned up: %d", tt.name, len(l.loading))
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package niu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrNotStruct = errors.New("type must be a struct")
+
+// 与 go-redis 的 Scan 一致，只处理带 redis 标签的导出字段，`redis:"-"` 表示忽略
+const redisStructTag = "redis"
+
+type redisStructField struct {
+	index int
+	name  string
+}
+
+// key: reflect.Type, value: []redisStructField
+var redisStructFields sync.Map
+
+func getRedisStructFields(t reflect.Type) []redisStructField {
+	if v, ok := redisStructFields.Load(t); ok {
+		return v.([]redisStructField)
+	}
+
+	fields := make([]redisStructField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := f.Tag.Get(redisStructTag)
+		if !f.IsExported() || name == "" || name == "-" {
+			continue
+		}
+		fields = append(fields, redisStructField{i, name})
+	}
+	redisStructFields.Store(t, fields)
+	return fields
+}
+
+// 读取整个 hash 并按 redis 标签填充到结构体中
+// 支持字符串、布尔、整数、浮点数、[]byte 以及 time.Time（RFC3339Nano 格式）
+// hash 中不存在的字段保持零值；key 不存在时返回 redis.Nil；字段值无法转换时返回错误
+func HGetAllStruct[T any](ctx context.Context, c *Cache, key string) (T, error) {
+	var out T
+	rv := reflect.ValueOf(&out).Elem()
+	if rv.Kind() != reflect.Struct {
+		return out, ErrNotStruct
+	}
+
+	values, err := c.slave.HGetAll(ctx, key).Result()
+	if err != nil {
+		return out, err
+	}
+	if len(values) == 0 {
+		return out, redis.Nil
+	}
+
+	for _, f := range getRedisStructFields(rv.Type()) {
+		str, ok := values[f.name]
+		if !ok {
+			continue
+		}
+		if err := setRedisField(rv.Field(f.index), str); err != nil {
+			return out, fmt.Errorf("field %s: %w", f.name, err)
+		}
+	}
+	return out, nil
+}
+
+// 将结构体按 redis 标签写入 hash，与 HGetAllStruct 对应
+// 零值字段同样会写入；已存在但结构体中没有的字段不会被删除
+func HSetStruct[T any](ctx context.Context, c *Cache, key string, val T) (int64, error) {
+	rv := reflect.ValueOf(val)
+	if rv.Kind() != reflect.Struct {
+		return 0, ErrNotStruct
+	}
+
+	fields := getRedisStructFields(rv.Type())
+	values := make(map[string]any, len(fields))
+	for _, f := range fields {
+		str, err := formatRedisField(rv.Field(f.index))
+		if err != nil {
+			return 0, fmt.Errorf("field %s: %w", f.name, err)
+		}
+		values[f.name] = str
+	}
+	if len(values) == 0 {
+		return 0, nil
+	}
+	return c.master.HSet(ctx, key, values).Result()
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func setRedisField(v reflect.Value, str string) error {
+	if v.Type() == timeType {
+		t, err := time.Parse(time.RFC3339Nano, str)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(str)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(str)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(str, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(str, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(str, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		v.SetBytes([]byte(str))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+func formatRedisField(v reflect.Value) (string, error) {
+	if v.Type() == timeType {
+		return v.Interface().(time.Time).Format(time.RFC3339Nano), nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return string(v.Bytes()), nil
+		}
+	}
+	return "", fmt.Errorf("unsupported type %s", v.Type())
+}
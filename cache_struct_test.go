@@ -0,0 +1,111 @@
+package niu
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type testProfile struct {
+	Name     string    `redis:"name"`
+	Age      int       `redis:"age"`
+	Score    float64   `redis:"score"`
+	Vip      bool      `redis:"vip"`
+	Avatar   []byte    `redis:"avatar"`
+	Birthday time.Time `redis:"birthday"`
+	Ignored  string    `redis:"-"`
+	Untagged string
+}
+
+func TestHStructRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	c, mr := newTestCache(t)
+	in := testProfile{
+		Name:     "a",
+		Age:      18,
+		Score:    9.5,
+		Vip:      true,
+		Avatar:   []byte{1, 2},
+		Birthday: time.Date(2000, 1, 2, 3, 4, 5, 6, time.UTC),
+		Ignored:  "x",
+		Untagged: "y",
+	}
+	if _, err := HSetStruct(ctx, c, "p", in); err != nil {
+		t.Fatal(err)
+	}
+	if keys, _ := mr.HKeys("p"); len(keys) != 6 {
+		t.Fatalf("hash fields = %v, want only tagged fields", keys)
+	}
+
+	out, err := HGetAllStruct[testProfile](ctx, c, "p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	in.Ignored, in.Untagged = "", ""
+	if out.Name != in.Name || out.Age != in.Age || out.Score != in.Score || out.Vip != in.Vip ||
+		string(out.Avatar) != string(in.Avatar) || !out.Birthday.Equal(in.Birthday) || out.Ignored != "" || out.Untagged != "" {
+		t.Fatalf("got %+v, want %+v", out, in)
+	}
+}
+
+func TestHGetAllStructMissing(t *testing.T) {
+	ctx := context.Background()
+	c, mr := newTestCache(t)
+	if _, err := HGetAllStruct[testProfile](ctx, c, "none"); !errors.Is(err, redis.Nil) {
+		t.Fatalf("missing key: %v, want redis.Nil", err)
+	}
+
+	// 缺少的字段保持零值
+	mr.HSet("p", "name", "a", "extra", "ignored")
+	out, err := HGetAllStruct[testProfile](ctx, c, "p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Name != "a" || out.Age != 0 || out.Vip || !out.Birthday.IsZero() {
+		t.Fatalf("got %+v", out)
+	}
+}
+
+func TestHStructConversionErrors(t *testing.T) {
+	ctx := context.Background()
+	c, mr := newTestCache(t)
+	tests := []struct {
+		field, value string
+	}{
+		{"age", "abc"},
+		{"age", "99999999999999999999"},
+		{"score", "x"},
+		{"vip", "maybe"},
+		{"birthday", "2000-01-02"},
+	}
+	for _, tt := range tests {
+		mr.Del("p")
+		mr.HSet("p", tt.field, tt.value)
+		_, err := HGetAllStruct[testProfile](ctx, c, "p")
+		if err == nil || !strings.Contains(err.Error(), "field "+tt.field) {
+			t.Errorf("%s=%q: %v, want a conversion error naming the field", tt.field, tt.value, err)
+		}
+	}
+
+	type unsupported struct {
+		Tags []string `redis:"tags"`
+	}
+	if _, err := HSetStruct(ctx, c, "u", unsupported{}); err == nil {
+		t.Error("unsupported field type should fail on write")
+	}
+	mr.HSet("u", "tags", "a")
+	if _, err := HGetAllStruct[unsupported](ctx, c, "u"); err == nil {
+		t.Error("unsupported field type should fail on read")
+	}
+
+	if _, err := HGetAllStruct[int](ctx, c, "p"); !errors.Is(err, ErrNotStruct) {
+		t.Errorf("non-struct read: %v, want ErrNotStruct", err)
+	}
+	if _, err := HSetStruct(ctx, c, "p", 1); !errors.Is(err, ErrNotStruct) {
+		t.Errorf("non-struct write: %v, want ErrNotStruct", err)
+	}
+}
@@ -0,0 +1,127 @@
+package niu
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCompareAndSwap(t *testing.T) {
+	ctx := context.Background()
+	c, mr := newTestCache(t)
+
+	if ok, err := c.CompareAndSwap(ctx, "k", "", "v", 0); err != nil || ok {
+		t.Fatalf("missing key: %v %v, want false", ok, err)
+	}
+	if mr.Exists("k") {
+		t.Fatal("missing key should not be created")
+	}
+
+	mr.Set("k", "v1")
+	if ok, err := c.CompareAndSwap(ctx, "k", "other", "v2", 0); err != nil || ok {
+		t.Fatalf("mismatch: %v %v, want false", ok, err)
+	}
+	if v, _ := mr.Get("k"); v != "v1" {
+		t.Fatalf("mismatch changed value to %q", v)
+	}
+
+	if ok, err := c.CompareAndSwap(ctx, "k", "v1", "v2", time.Minute); err != nil || !ok {
+		t.Fatalf("match: %v %v, want true", ok, err)
+	}
+	if v, _ := mr.Get("k"); v != "v2" || mr.TTL("k") != time.Minute {
+		t.Fatalf("after swap value %q ttl %v", v, mr.TTL("k"))
+	}
+	// expiry 为0时不过期
+	if ok, _ := c.CompareAndSwap(ctx, "k", "v2", "v3", 0); !ok || mr.TTL("k") != 0 {
+		t.Fatalf("swap without expiry: %v ttl %v", ok, mr.TTL("k"))
+	}
+}
+
+// 多个调用方基于同一个旧值竞争，只有一个能成功
+func TestCompareAndSwapContended(t *testing.T) {
+	ctx := context.Background()
+	c, mr := newTestCache(t)
+	mr.Set("k", "0")
+
+	var wins atomic.Int32
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := c.CompareAndSwap(ctx, "k", "0", strconv.Itoa(i+1), 0)
+			if err != nil {
+				t.Error(err)
+			}
+			if ok {
+				wins.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if wins.Load() != 1 {
+		t.Fatalf("%d callers swapped, want 1", wins.Load())
+	}
+	if v, _ := mr.Get("k"); v == "0" {
+		t.Fatal("value not swapped")
+	}
+}
+
+func TestSetNXJson(t *testing.T) {
+	ctx := context.Background()
+	c, mr := newTestCache(t)
+	if ok, err := c.SetNXJson(ctx, "k", map[string]int{"a": 1}, time.Minute); err != nil || !ok {
+		t.Fatalf("first set: %v %v", ok, err)
+	}
+	if ok, err := c.SetNXJson(ctx, "k", map[string]int{"a": 2}, time.Minute); err != nil || ok {
+		t.Fatalf("second set: %v %v, want false", ok, err)
+	}
+	if v, _ := mr.Get("k"); v != `{"a":1}` {
+		t.Fatalf("value = %q", v)
+	}
+}
+
+func TestTTL(t *testing.T) {
+	ctx := context.Background()
+	c, mr := newTestCache(t)
+	mr.Set("expiring", "v")
+	mr.SetTTL("expiring", 90*time.Second)
+	mr.Set("persistent", "v")
+
+	if d, err := c.TTL(ctx, "expiring"); err != nil || d != 90*time.Second {
+		t.Fatalf("TTL = %v %v, want 90s", d, err)
+	}
+	if d, err := c.PTTL(ctx, "expiring"); err != nil || d != 90*time.Second {
+		t.Fatalf("PTTL = %v %v, want 90s", d, err)
+	}
+	for _, fn := range []func(context.Context, string) (time.Duration, error){c.TTL, c.PTTL} {
+		if _, err := fn(ctx, "persistent"); !errors.Is(err, ErrKeyNoExpiry) {
+			t.Errorf("persistent key: %v, want ErrKeyNoExpiry", err)
+		}
+		if _, err := fn(ctx, "missing"); !errors.Is(err, ErrKeyNotExist) {
+			t.Errorf("missing key: %v, want ErrKeyNotExist", err)
+		}
+	}
+}
+
+func TestMultiExists(t *testing.T) {
+	ctx := context.Background()
+	c, mr := newTestCache(t)
+	mr.Set("a", "1")
+	mr.HSet("h", "f", "1")
+
+	got, err := c.MultiExists(ctx, "a", "missing", "h", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || !got["a"] || got["missing"] || !got["h"] {
+		t.Fatalf("got %v", got)
+	}
+	if got, err := c.MultiExists(ctx); err != nil || got == nil || len(got) != 0 {
+		t.Fatalf("no keys: %v %v, want an empty map", got, err)
+	}
+}
@@ -5,3 +5,11 @@ type Collection[T any] interface {
 	IsEmpty() bool
 	Clear()
 }
+
+// 有容量限制的集合已满时的处理方式
+type OverflowPolicy int
+
+const (
+	OverflowReject      OverflowPolicy = iota // 拒绝新加入的元素
+	OverflowEvictOldest                       // 移除最早加入的元素
+)
@@ -0,0 +1,155 @@
+package niu
+
+import (
+	"container/list"
+	"sync"
+)
+
+var _ Collection[int] = (*LRU[int, int])(nil)
+
+type lruEntry[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+// 最近最少使用缓存，超出 maxSize 时淘汰最久未访问的元素，maxSize <= 0 表示不限制
+// 非并发安全，多个协程共享时使用 SyncLRU
+type LRU[K comparable, V any] struct {
+	maxSize int
+	onEvict func(key K, value V)
+	ll      *list.List
+	items   map[K]*list.Element
+}
+
+// onEvict 仅在因超出容量而淘汰元素时调用，可以为 nil
+func NewLRU[K comparable, V any](maxSize int, onEvict func(key K, value V)) *LRU[K, V] {
+	return &LRU[K, V]{
+		maxSize: maxSize,
+		onEvict: onEvict,
+		ll:      list.New(),
+		items:   make(map[K]*list.Element),
+	}
+}
+
+// 读取并将其标记为最近访问
+// O(1)
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	el, ok := c.items[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	c.ll.MoveToFront(el)
+	return el.Value.(*lruEntry[K, V]).value, true
+}
+
+// 读取但不改变访问顺序
+// O(1)
+func (c *LRU[K, V]) Peek(key K) (V, bool) {
+	el, ok := c.items[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	return el.Value.(*lruEntry[K, V]).value, true
+}
+
+// 已存在时更新值并标记为最近访问
+// O(1)
+func (c *LRU[K, V]) Put(key K, value V) {
+	if el, ok := c.items[key]; ok {
+		el.Value.(*lruEntry[K, V]).value = value
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&lruEntry[K, V]{key, value})
+	if c.maxSize > 0 && c.ll.Len() > c.maxSize {
+		oldest := c.ll.Back()
+		entry := oldest.Value.(*lruEntry[K, V])
+		c.ll.Remove(oldest)
+		delete(c.items, entry.key)
+		if c.onEvict != nil {
+			c.onEvict(entry.key, entry.value)
+		}
+	}
+}
+
+// 返回 key 是否存在，不会调用 onEvict
+// O(1)
+func (c *LRU[K, V]) Remove(key K) bool {
+	el, ok := c.items[key]
+	if !ok {
+		return false
+	}
+	c.ll.Remove(el)
+	delete(c.items, key)
+	return true
+}
+
+// O(1)
+func (c *LRU[K, V]) Clear() {
+	c.ll.Init()
+	clear(c.items)
+}
+
+// O(1)
+func (c *LRU[K, V]) Size() int { return c.ll.Len() }
+
+// O(1)
+func (c *LRU[K, V]) IsEmpty() bool { return c.ll.Len() == 0 }
+
+var _ Collection[int] = (*SyncLRU[int, int])(nil)
+
+// 并发安全的 LRU，onEvict 在持有锁时调用，不能在其中访问该缓存
+type SyncLRU[K comparable, V any] struct {
+	lru  *LRU[K, V]
+	lock sync.Mutex
+}
+
+func NewSyncLRU[K comparable, V any](maxSize int, onEvict func(key K, value V)) *SyncLRU[K, V] {
+	return &SyncLRU[K, V]{lru: NewLRU(maxSize, onEvict)}
+}
+
+// Get 会改变访问顺序，因此与 Put 一样使用互斥锁
+func (c *SyncLRU[K, V]) Get(key K) (V, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.lru.Get(key)
+}
+
+func (c *SyncLRU[K, V]) Peek(key K) (V, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.lru.Peek(key)
+}
+
+func (c *SyncLRU[K, V]) Put(key K, value V) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.lru.Put(key, value)
+}
+
+func (c *SyncLRU[K, V]) Remove(key K) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.lru.Remove(key)
+}
+
+func (c *SyncLRU[K, V]) Clear() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.lru.Clear()
+}
+
+func (c *SyncLRU[K, V]) Size() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.lru.Size()
+}
+
+func (c *SyncLRU[K, V]) IsEmpty() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.lru.IsEmpty()
+}
@@ -0,0 +1,101 @@
+package niu
+
+import (
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestLRUEviction(t *testing.T) {
+	var evicted []string
+	c := NewLRU(3, func(key string, value int) { evicted = append(evicted, key) })
+	c.Put("a", 1)
+	c.Put("b", 2)
+	c.Put("c", 3)
+
+	// Get 将 a 标记为最近访问，Peek 不改变顺序，因此淘汰 b
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Fatalf("Get(a) = %d %v", v, ok)
+	}
+	if v, ok := c.Peek("b"); !ok || v != 2 {
+		t.Fatalf("Peek(b) = %d %v", v, ok)
+	}
+	c.Put("d", 4)
+	if !slices.Equal(evicted, []string{"b"}) {
+		t.Fatalf("evicted %v, want [b]", evicted)
+	}
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("b should be evicted")
+	}
+
+	// 更新已有的 key 同样标记为最近访问，不会淘汰
+	c.Put("c", 30)
+	if c.Size() != 3 || len(evicted) != 1 {
+		t.Fatalf("update evicted: size %d evicted %v", c.Size(), evicted)
+	}
+	c.Put("e", 5)
+	c.Put("f", 6)
+	if !slices.Equal(evicted, []string{"b", "a", "d"}) {
+		t.Fatalf("evicted %v, want [b a d]", evicted)
+	}
+	if v, ok := c.Get("c"); !ok || v != 30 {
+		t.Fatalf("Get(c) = %d %v", v, ok)
+	}
+}
+
+// 只有超出容量的淘汰才调用 onEvict
+func TestLRUOnEvictOnlyOnCapacity(t *testing.T) {
+	calls := 0
+	c := NewLRU(2, func(string, int) { calls++ })
+	c.Put("a", 1)
+	c.Put("b", 2)
+	if !c.Remove("a") || c.Remove("a") {
+		t.Fatal("Remove should report whether the key existed")
+	}
+	c.Put("c", 3)
+	c.Clear()
+	if calls != 0 || !c.IsEmpty() {
+		t.Fatalf("onEvict called %d times by Remove/Clear", calls)
+	}
+	c.Put("a", 1)
+	c.Put("b", 2)
+	c.Put("c", 3)
+	if calls != 1 {
+		t.Fatalf("onEvict called %d times, want 1", calls)
+	}
+}
+
+func TestLRUUnbounded(t *testing.T) {
+	c := NewLRU[int, int](0, nil)
+	for i := range 1000 {
+		c.Put(i, i)
+	}
+	if c.Size() != 1000 {
+		t.Fatalf("size = %d", c.Size())
+	}
+}
+
+func TestSyncLRUConcurrent(t *testing.T) {
+	var evicted sync.Map
+	c := NewSyncLRU(100, func(key, value int) { evicted.Store(key, value) })
+	var wg sync.WaitGroup
+	for w := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 500 {
+				key := w*500 + i
+				c.Put(key, key)
+				c.Get(key)
+				c.Peek(key - 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	n := 0
+	evicted.Range(func(any, any) bool { n++; return true })
+	if c.Size() != 100 || n != 8*500-100 {
+		t.Fatalf("size %d evicted %d", c.Size(), n)
+	}
+}
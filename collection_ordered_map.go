@@ -0,0 +1,123 @@
+package niu
+
+import (
+	"container/list"
+	"iter"
+)
+
+var _ Collection[int] = (*OrderedMap[int, int])(nil)
+
+type orderedMapEntry[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+// 按插入顺序遍历的 map，更新已有的 key 不改变其位置，删除后重新插入则排在最后
+// 零值可直接使用；非并发安全
+type OrderedMap[K comparable, V any] struct {
+	ll    *list.List
+	items map[K]*list.Element
+}
+
+func NewOrderedMap[K comparable, V any]() *OrderedMap[K, V] {
+	m := &OrderedMap[K, V]{}
+	m.ensureInit()
+	return m
+}
+
+func (m *OrderedMap[K, V]) ensureInit() {
+	if m.items == nil {
+		m.ll = list.New()
+		m.items = make(map[K]*list.Element)
+	}
+}
+
+// O(1)
+func (m *OrderedMap[K, V]) Set(key K, value V) {
+	m.ensureInit()
+	if el, ok := m.items[key]; ok {
+		el.Value.(*orderedMapEntry[K, V]).value = value
+		return
+	}
+	m.items[key] = m.ll.PushBack(&orderedMapEntry[K, V]{key, value})
+}
+
+// O(1)
+func (m *OrderedMap[K, V]) Get(key K) (V, bool) {
+	el, ok := m.items[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	return el.Value.(*orderedMapEntry[K, V]).value, true
+}
+
+// O(1)
+func (m *OrderedMap[K, V]) Has(key K) bool {
+	_, ok := m.items[key]
+	return ok
+}
+
+// 返回 key 是否存在
+// O(1)
+func (m *OrderedMap[K, V]) Delete(key K) bool {
+	el, ok := m.items[key]
+	if !ok {
+		return false
+	}
+	m.ll.Remove(el)
+	delete(m.items, key)
+	return true
+}
+
+// 按插入顺序返回所有的 key
+// O(n)
+func (m *OrderedMap[K, V]) Keys() []K {
+	out := make([]K, 0, len(m.items))
+	for k := range m.All() {
+		out = append(out, k)
+	}
+	return out
+}
+
+// 按插入顺序返回所有的值
+// O(n)
+func (m *OrderedMap[K, V]) Values() []V {
+	out := make([]V, 0, len(m.items))
+	for _, v := range m.All() {
+		out = append(out, v)
+	}
+	return out
+}
+
+// 按插入顺序遍历，循环体内可以删除当前的 key
+func (m *OrderedMap[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		if m.ll == nil {
+			return
+		}
+		for el := m.ll.Front(); el != nil; {
+			// 先取下一个节点，当前节点被删除后其 Next 为 nil
+			next := el.Next()
+			entry := el.Value.(*orderedMapEntry[K, V])
+			if !yield(entry.key, entry.value) {
+				return
+			}
+			el = next
+		}
+	}
+}
+
+// O(1)
+func (m *OrderedMap[K, V]) Clear() {
+	if m.ll != nil {
+		m.ll.Init()
+		clear(m.items)
+	}
+}
+
+// O(1)
+func (m *OrderedMap[K, V]) Size() int { return len(m.items) }
+
+// O(1)
+func (m *OrderedMap[K, V]) IsEmpty() bool { return len(m.items) == 0 }
@@ -0,0 +1,83 @@
+package niu
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestOrderedMapOrder(t *testing.T) {
+	m := NewOrderedMap[string, int]()
+	m.Set("a", 1)
+	m.Set("b", 2)
+	m.Set("c", 3)
+	m.Set("a", 10) // 更新不改变位置
+	if keys := m.Keys(); !slices.Equal(keys, []string{"a", "b", "c"}) {
+		t.Fatalf("keys = %v", keys)
+	}
+	if values := m.Values(); !slices.Equal(values, []int{10, 2, 3}) {
+		t.Fatalf("values = %v", values)
+	}
+
+	// 删除后重新插入排在最后
+	if !m.Delete("a") || m.Delete("a") {
+		t.Fatal("Delete should report whether the key existed")
+	}
+	m.Set("a", 1)
+	if keys := m.Keys(); !slices.Equal(keys, []string{"b", "c", "a"}) {
+		t.Fatalf("keys after re-insert = %v", keys)
+	}
+	if v, ok := m.Get("a"); !ok || v != 1 || !m.Has("b") || m.Has("x") || m.Size() != 3 {
+		t.Fatal("lookup after re-insert")
+	}
+	if _, ok := m.Get("x"); ok {
+		t.Fatal("missing key should not be found")
+	}
+}
+
+func TestOrderedMapDeleteDuringAll(t *testing.T) {
+	m := NewOrderedMap[int, int]()
+	for i := range 6 {
+		m.Set(i, i)
+	}
+	var visited []int
+	for k := range m.All() {
+		visited = append(visited, k)
+		if k%2 == 0 {
+			m.Delete(k)
+		}
+	}
+	if !slices.Equal(visited, []int{0, 1, 2, 3, 4, 5}) {
+		t.Fatalf("visited %v", visited)
+	}
+	if keys := m.Keys(); !slices.Equal(keys, []int{1, 3, 5}) {
+		t.Fatalf("keys after delete = %v", keys)
+	}
+
+	// 提前退出
+	var first []int
+	for k := range m.All() {
+		first = append(first, k)
+		if len(first) == 2 {
+			break
+		}
+	}
+	if !slices.Equal(first, []int{1, 3}) {
+		t.Fatalf("break got %v", first)
+	}
+}
+
+func TestOrderedMapZeroValue(t *testing.T) {
+	var m OrderedMap[string, int]
+	if m.Size() != 0 || !m.IsEmpty() || m.Has("a") || m.Delete("a") || len(m.Keys()) != 0 {
+		t.Fatal("zero value should be empty")
+	}
+	m.Clear()
+	m.Set("a", 1)
+	if v, ok := m.Get("a"); !ok || v != 1 {
+		t.Fatal("zero value should be usable")
+	}
+	m.Clear()
+	if !m.IsEmpty() || len(m.Keys()) != 0 {
+		t.Fatal("not empty after Clear")
+	}
+}
@@ -0,0 +1,99 @@
+package niu
+
+import (
+	"container/heap"
+	"sync"
+)
+
+var _ Collection[int] = (*PriorityQueue[int])(nil)
+
+// container/heap 所需的底层切片
+type priorityQueueHeap[T any] struct {
+	items []*T
+	less  func(a, b *T) bool
+}
+
+func (h *priorityQueueHeap[T]) Len() int           { return len(h.items) }
+func (h *priorityQueueHeap[T]) Less(i, j int) bool { return h.less(h.items[i], h.items[j]) }
+func (h *priorityQueueHeap[T]) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *priorityQueueHeap[T]) Push(x any)         { h.items = append(h.items, x.(*T)) }
+func (h *priorityQueueHeap[T]) Pop() any {
+	n := len(h.items) - 1
+	out := h.items[n]
+	h.items[n] = nil
+	h.items = h.items[:n]
+	return out
+}
+
+// 基于堆的优先队列，less(a, b) 为 true 时 a 先出队，即按 less 排序的最小堆
+// 内部使用读写锁，可在多个协程间共享；less 相等的元素出队顺序不固定
+type PriorityQueue[T any] struct {
+	h    priorityQueueHeap[T]
+	lock sync.RWMutex
+}
+
+func NewPriorityQueue[T any](less func(a, b *T) bool) *PriorityQueue[T] {
+	return &PriorityQueue[T]{h: priorityQueueHeap[T]{less: less}}
+}
+
+// O(log n)
+func (q *PriorityQueue[T]) Push(val *T) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	heap.Push(&q.h, val)
+}
+
+// O(n)
+func (q *PriorityQueue[T]) PushAll(vals ...T) {
+	if len(vals) == 0 {
+		return
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for _, v := range vals {
+		q.h.items = append(q.h.items, &v)
+	}
+	heap.Init(&q.h)
+}
+
+// 取出最小的元素，队列为空时返回 nil
+// O(log n)
+func (q *PriorityQueue[T]) Pop() *T {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if len(q.h.items) == 0 {
+		return nil
+	}
+	return heap.Pop(&q.h).(*T)
+}
+
+// O(1)
+func (q *PriorityQueue[T]) Peek() *T {
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+	if len(q.h.items) == 0 {
+		return nil
+	}
+	return q.h.items[0]
+}
+
+// O(1)
+func (q *PriorityQueue[T]) Clear() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.h.items = nil
+}
+
+// O(1)
+func (q *PriorityQueue[T]) Size() int {
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+	return len(q.h.items)
+}
+
+// O(1)
+func (q *PriorityQueue[T]) IsEmpty() bool {
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+	return len(q.h.items) == 0
+}
@@ -0,0 +1,72 @@
+package niu
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func intLess(a, b *int) bool { return *a < *b }
+
+func TestPriorityQueueOrder(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	values := r.Perm(200)
+	for i := range 50 {
+		values = append(values, i) // 重复的元素
+	}
+
+	q := NewPriorityQueue(intLess)
+	for _, v := range values[:100] {
+		q.Push(&v)
+	}
+	q.PushAll(values[100:]...)
+	if q.Size() != len(values) {
+		t.Fatalf("size = %d, want %d", q.Size(), len(values))
+	}
+
+	want := slices.Clone(values)
+	slices.Sort(want)
+	got := make([]int, 0, len(values))
+	for !q.IsEmpty() {
+		peek := q.Peek()
+		v := q.Pop()
+		if peek != v {
+			t.Fatalf("Peek returned %d but Pop returned %d", *peek, *v)
+		}
+		got = append(got, *v)
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("pop order not sorted: %v", got)
+	}
+}
+
+func TestPriorityQueueEmpty(t *testing.T) {
+	q := NewPriorityQueue(intLess)
+	if q.Pop() != nil || q.Peek() != nil || !q.IsEmpty() || q.Size() != 0 {
+		t.Fatal("empty queue should return nil")
+	}
+	q.PushAll()
+	one := 1
+	q.Push(&one)
+	if q.Pop() != &one || q.Pop() != nil {
+		t.Fatal("pop after draining should return nil")
+	}
+
+	q.PushAll(3, 1, 2)
+	q.Clear()
+	if q.Pop() != nil || q.Peek() != nil || q.Size() != 0 {
+		t.Fatal("queue not empty after Clear")
+	}
+}
+
+// 按 less 取反得到最大堆
+func TestPriorityQueueMaxHeap(t *testing.T) {
+	q := NewPriorityQueue(func(a, b *int) bool { return *a > *b })
+	q.PushAll(2, 9, 4)
+	if v := *q.Pop(); v != 9 {
+		t.Fatalf("pop = %d, want 9", v)
+	}
+	if v := *q.Peek(); v != 4 {
+		t.Fatalf("peek = %d, want 4", v)
+	}
+}
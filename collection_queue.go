@@ -2,6 +2,7 @@ package niu
 
 import (
 	"fmt"
+	"iter"
 	"sync"
 )
 
@@ -10,52 +11,79 @@ type fastQueueNode[T any] struct {
 	next  *fastQueueNode[T]
 }
 
+var _ Collection[int] = (*FastQueue[int])(nil)
+
+// 基于链表的先进先出队列，内部使用读写锁，可在多个协程间共享
+// 默认不限制容量，可通过 SetCapacity 限制
 type FastQueue[T any] struct {
-	first *fastQueueNode[T]
-	last  *fastQueueNode[T]
-	size  int
-	lock  sync.RWMutex
+	first    *fastQueueNode[T]
+	last     *fastQueueNode[T]
+	size     int
+	capacity int
+	policy   OverflowPolicy
+	lock     sync.RWMutex
 }
 
-// O(1)
-func (queue *FastQueue[T]) In(val *T) {
+// 限制队列最多容纳 capacity 个元素，capacity <= 0 表示不限制
+// 当前元素个数已超过 capacity 时：OverflowEvictOldest 立即移除最早入队的元素，OverflowReject 保留已有元素
+func (queue *FastQueue[T]) SetCapacity(capacity int, policy OverflowPolicy) {
 	queue.lock.Lock()
 	defer queue.lock.Unlock()
 
-	node := &fastQueueNode[T]{value: val, next: nil}
-	if queue.last != nil {
-		queue.last.next = node
+	queue.capacity = capacity
+	queue.policy = policy
+	if policy == OverflowEvictOldest {
+		for capacity > 0 && queue.size > capacity {
+			queue.out()
+		}
 	}
-	queue.last = node
+}
 
-	if queue.first == nil {
-		queue.first = node
-	}
-	queue.size++
+// 返回是否入队，队列已满且策略为 OverflowReject 时返回 false
+// O(1)
+func (queue *FastQueue[T]) In(val *T) bool {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+
+	return queue.in(val)
 }
 
+// 返回入队的元素个数，队列已满且策略为 OverflowReject 时之后的元素不会入队
 // O(n)
-func (queue *FastQueue[T]) InAll(vals ...T) {
-	l := len(vals)
-	if l == 0 {
-		return
+func (queue *FastQueue[T]) InAll(vals ...T) int {
+	if len(vals) == 0 {
+		return 0
 	}
 	queue.lock.Lock()
 	defer queue.lock.Unlock()
 
-	for _, v := range vals {
-		node := &fastQueueNode[T]{value: &v, next: nil}
-		if queue.last != nil {
-			queue.last.next = node
+	for i, v := range vals {
+		if !queue.in(&v) {
+			return i
 		}
-		queue.last = node
+	}
+	return len(vals)
+}
 
-		if queue.first == nil {
-			queue.first = node
+func (queue *FastQueue[T]) in(val *T) bool {
+	if queue.capacity > 0 && queue.size >= queue.capacity {
+		if queue.policy == OverflowReject {
+			return false
 		}
+		queue.out()
 	}
 
-	queue.size += l
+	node := &fastQueueNode[T]{value: val, next: nil}
+	if queue.last != nil {
+		queue.last.next = node
+	}
+	queue.last = node
+
+	if queue.first == nil {
+		queue.first = node
+	}
+	queue.size++
+	return true
 }
 
 // O(1)
@@ -63,17 +91,35 @@ func (queue *FastQueue[T]) Out() *T {
 	queue.lock.Lock()
 	defer queue.lock.Unlock()
 
+	return queue.out()
+}
+
+func (queue *FastQueue[T]) out() *T {
 	if queue.first == nil {
 		return nil
 	}
 
 	out := queue.first
 	queue.first = out.next
+	if queue.first == nil {
+		queue.last = nil
+	}
 	queue.size--
 
 	return out.value
 }
 
+// O(1)
+func (queue *FastQueue[T]) Peek() *T {
+	queue.lock.RLock()
+	defer queue.lock.RUnlock()
+
+	if queue.first == nil {
+		return nil
+	}
+	return queue.first.value
+}
+
 // O(1)
 func (queue *FastQueue[T]) Clear() {
 	queue.lock.Lock()
@@ -97,6 +143,21 @@ func (queue *FastQueue[T]) IsEmpty() bool {
 	return queue.size == 0
 }
 
+// 按先进先出的顺序（first->last）遍历，不会出队，元素与 Out 返回的指针相同
+// 遍历期间持有读锁，循环体内不能修改该队列，否则会死锁
+func (queue *FastQueue[T]) All() iter.Seq[*T] {
+	return func(yield func(*T) bool) {
+		queue.lock.RLock()
+		defer queue.lock.RUnlock()
+
+		for node := queue.first; node != nil; node = node.next {
+			if !yield(node.value) {
+				return
+			}
+		}
+	}
+}
+
 // for test only
 func (queue *FastQueue[T]) Print() {
 	fmt.Println("--------start print queue[first->last]----------")
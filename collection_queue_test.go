@@ -0,0 +1,152 @@
+package niu
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestFastQueueConcurrent(t *testing.T) {
+	var q FastQueue[int]
+	var wg sync.WaitGroup
+	var popped atomic.Int32
+	for range 4 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := range 500 {
+				q.In(&i)
+				q.Peek()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for range 400 {
+				if q.Out() != nil {
+					popped.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if int(popped.Load())+q.Size() != 2000 {
+		t.Fatalf("popped %d + remaining %d != 2000", popped.Load(), q.Size())
+	}
+}
+
+func TestFastQueueAll(t *testing.T) {
+	var q FastQueue[int]
+	q.InAll(1, 2, 3)
+	var got []int
+	for v := range q.All() {
+		got = append(got, *v)
+	}
+	if !slices.Equal(got, []int{1, 2, 3}) {
+		t.Fatalf("All() = %v, want first->last", got)
+	}
+	if q.Size() != 3 {
+		t.Fatal("All should not dequeue")
+	}
+
+	got = got[:0]
+	for v := range q.All() {
+		got = append(got, *v)
+		if len(got) == 2 {
+			break
+		}
+	}
+	if !slices.Equal(got, []int{1, 2}) {
+		t.Fatalf("break got %v", got)
+	}
+	// 提前退出后读锁已释放
+	q.In(new(int))
+}
+
+func queueValues(q *FastQueue[int]) []int {
+	var out []int
+	for v := range q.All() {
+		out = append(out, *v)
+	}
+	return out
+}
+
+func TestFastQueueCapacityReject(t *testing.T) {
+	var q FastQueue[int]
+	q.SetCapacity(3, OverflowReject)
+	if n := q.InAll(1, 2); n != 2 {
+		t.Fatalf("InAll = %d, want 2", n)
+	}
+	if n := q.InAll(3, 4, 5); n != 1 {
+		t.Fatalf("InAll on a nearly full queue = %d, want 1", n)
+	}
+	four := 4
+	if q.In(&four) {
+		t.Fatal("In on a full queue should return false")
+	}
+	if got := queueValues(&q); !slices.Equal(got, []int{1, 2, 3}) {
+		t.Fatalf("queue = %v", got)
+	}
+
+	q.Out()
+	if !q.In(&four) {
+		t.Fatal("In after Out should succeed")
+	}
+	// 缩小容量时保留已有元素
+	q.SetCapacity(1, OverflowReject)
+	if q.Size() != 3 || q.In(&four) {
+		t.Fatalf("shrink with reject: size %d", q.Size())
+	}
+}
+
+func TestFastQueueCapacityEvictOldest(t *testing.T) {
+	var q FastQueue[int]
+	q.SetCapacity(3, OverflowEvictOldest)
+	if n := q.InAll(1, 2, 3, 4, 5); n != 5 {
+		t.Fatalf("InAll = %d, want 5", n)
+	}
+	six := 6
+	if !q.In(&six) {
+		t.Fatal("In with evict policy should always succeed")
+	}
+	if got := queueValues(&q); !slices.Equal(got, []int{4, 5, 6}) {
+		t.Fatalf("queue = %v, want the newest 3", got)
+	}
+
+	// 缩小容量时立即移除最早的元素
+	q.SetCapacity(1, OverflowEvictOldest)
+	if got := queueValues(&q); !slices.Equal(got, []int{6}) {
+		t.Fatalf("after shrink = %v", got)
+	}
+	q.SetCapacity(0, OverflowReject)
+	if n := q.InAll(1, 2, 3, 4); n != 4 || q.Size() != 5 {
+		t.Fatalf("unbounded: InAll %d size %d", n, q.Size())
+	}
+}
+
+func TestFastQueuePeekClear(t *testing.T) {
+	var q FastQueue[int]
+	if q.Peek() != nil || q.Out() != nil {
+		t.Fatal("empty queue should return nil")
+	}
+
+	one := 1
+	q.In(&one)
+	if q.Peek() != &one || q.Size() != 1 {
+		t.Fatal("Peek should return the only element without removing it")
+	}
+	q.InAll(2, 3)
+	if v := q.Peek(); *v != 1 {
+		t.Fatalf("Peek = %d, want the first element", *v)
+	}
+
+	q.Clear()
+	if q.Peek() != nil || q.Out() != nil || !q.IsEmpty() || q.Size() != 0 {
+		t.Fatal("queue not empty after Clear")
+	}
+	// Clear 后仍可正常使用
+	q.InAll(4, 5)
+	if v := q.Out(); *v != 4 || *q.Peek() != 5 {
+		t.Fatal("queue unusable after Clear")
+	}
+}
@@ -1,7 +1,11 @@
 package niu
 
-import "sync"
+import (
+	"iter"
+	"sync"
+)
 
+// 集合，内部使用读写锁，可在多个协程间共享
 type Set[T comparable] struct {
 	underlying map[T]Empty
 	lock       sync.RWMutex
@@ -13,24 +17,35 @@ func (s *Set[T]) ensureInit() {
 	}
 }
 
+// 返回是否为新加入的元素
 // O(1)~O(n)
-func (s *Set[T]) Add(item T) {
+func (s *Set[T]) Add(item T) bool {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
 	s.ensureInit()
+	if _, ok := s.underlying[item]; ok {
+		return false
+	}
 	s.underlying[item] = Empty{}
+	return true
 }
 
+// 返回新加入的元素个数，items 中重复的元素只计一次
 // O(n)
-func (s *Set[T]) AddRange(items ...T) {
+func (s *Set[T]) AddRange(items ...T) int {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
 	s.ensureInit()
+	added := 0
 	for _, v := range items {
-		s.underlying[v] = Empty{}
+		if _, ok := s.underlying[v]; !ok {
+			s.underlying[v] = Empty{}
+			added++
+		}
 	}
+	return added
 }
 
 // O(1)~O(n)
@@ -77,6 +92,21 @@ func (s *Set[T]) ToSlice() []T {
 	return out
 }
 
+// 遍历集合中的元素，顺序不固定，不会复制底层数据
+// 遍历期间持有读锁，循环体内不能修改该集合，否则会死锁
+func (s *Set[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		s.lock.RLock()
+		defer s.lock.RUnlock()
+
+		for k := range s.underlying {
+			if !yield(k) {
+				return
+			}
+		}
+	}
+}
+
 // O(1)~O(n)
 func (s *Set[T]) Contains(item T) bool {
 	s.lock.RLock()
@@ -85,3 +115,63 @@ func (s *Set[T]) Contains(item T) bool {
 	_, ok := s.underlying[item]
 	return ok
 }
+
+// 复制一份底层数据，避免同时持有两个集合的锁（如 a.Union(b) 与 b.Union(a) 并发执行时）
+func (s *Set[T]) snapshot() map[T]Empty {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	out := make(map[T]Empty, len(s.underlying))
+	for k := range s.underlying {
+		out[k] = Empty{}
+	}
+	return out
+}
+
+// 并集，返回新的集合，不修改原集合
+// O(n+m)
+func (s *Set[T]) Union(other *Set[T]) *Set[T] {
+	out := s.snapshot()
+	for k := range other.snapshot() {
+		out[k] = Empty{}
+	}
+	return &Set[T]{underlying: out}
+}
+
+// 交集，返回新的集合，不修改原集合
+// O(n+m)
+func (s *Set[T]) Intersect(other *Set[T]) *Set[T] {
+	otherItems := other.snapshot()
+	out := map[T]Empty{}
+	for k := range s.snapshot() {
+		if _, ok := otherItems[k]; ok {
+			out[k] = Empty{}
+		}
+	}
+	return &Set[T]{underlying: out}
+}
+
+// 差集，即在 s 中但不在 other 中的元素，返回新的集合，不修改原集合
+// O(n+m)
+func (s *Set[T]) Difference(other *Set[T]) *Set[T] {
+	otherItems := other.snapshot()
+	out := map[T]Empty{}
+	for k := range s.snapshot() {
+		if _, ok := otherItems[k]; !ok {
+			out[k] = Empty{}
+		}
+	}
+	return &Set[T]{underlying: out}
+}
+
+// s 中的元素是否都在 other 中，空集是任意集合的子集
+// O(n+m)
+func (s *Set[T]) IsSubsetOf(other *Set[T]) bool {
+	otherItems := other.snapshot()
+	for k := range s.snapshot() {
+		if _, ok := otherItems[k]; !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,138 @@
+package niu
+
+import (
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestSetConcurrent(t *testing.T) {
+	var s Set[int]
+	var wg sync.WaitGroup
+	for w := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 500 {
+				s.Add(w*1000 + i)
+				s.Contains(i)
+				s.Size()
+				if i%10 == 0 {
+					s.Remove(w*1000 + i)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if n := s.Size(); n != 8*450 {
+		t.Fatalf("size = %d, want %d", n, 8*450)
+	}
+}
+
+func newTestSet(items ...int) *Set[int] {
+	s := &Set[int]{}
+	s.AddRange(items...)
+	return s
+}
+
+func sortedSet(s *Set[int]) []int {
+	out := s.ToSlice()
+	slices.Sort(out)
+	return out
+}
+
+func TestSetAlgebra(t *testing.T) {
+	tests := []struct {
+		name                   string
+		a, b                   []int
+		union, intersect, diff []int
+		subset                 bool
+	}{
+		{"overlap", []int{1, 2, 3}, []int{2, 3, 4}, []int{1, 2, 3, 4}, []int{2, 3}, []int{1}, false},
+		{"disjoint", []int{1}, []int{2}, []int{1, 2}, []int{}, []int{1}, false},
+		{"subset", []int{1, 2}, []int{1, 2, 3}, []int{1, 2, 3}, []int{1, 2}, []int{}, true},
+		{"equal", []int{1, 2}, []int{2, 1}, []int{1, 2}, []int{1, 2}, []int{}, true},
+		{"empty left", nil, []int{1}, []int{1}, []int{}, []int{}, true},
+		{"both empty", nil, nil, []int{}, []int{}, []int{}, true},
+	}
+	for _, tt := range tests {
+		a, b := newTestSet(tt.a...), newTestSet(tt.b...)
+		if got := sortedSet(a.Union(b)); !slices.Equal(got, tt.union) {
+			t.Errorf("%s: union = %v, want %v", tt.name, got, tt.union)
+		}
+		if got := sortedSet(a.Intersect(b)); !slices.Equal(got, tt.intersect) {
+			t.Errorf("%s: intersect = %v, want %v", tt.name, got, tt.intersect)
+		}
+		if got := sortedSet(a.Difference(b)); !slices.Equal(got, tt.diff) {
+			t.Errorf("%s: difference = %v, want %v", tt.name, got, tt.diff)
+		}
+		if got := a.IsSubsetOf(b); got != tt.subset {
+			t.Errorf("%s: subset = %v, want %v", tt.name, got, tt.subset)
+		}
+		// 不修改原集合
+		if got := sortedSet(a); !slices.Equal(got, slices.Sorted(slices.Values(tt.a))) {
+			t.Errorf("%s: left operand modified: %v", tt.name, got)
+		}
+	}
+
+	// 结果是独立的集合，可以继续修改
+	u := newTestSet(1).Union(newTestSet(2))
+	u.Add(3)
+	if u.Size() != 3 {
+		t.Fatalf("union result size = %d", u.Size())
+	}
+}
+
+// a.Union(b) 与 b.Union(a) 并发执行不会死锁
+func TestSetAlgebraConcurrent(t *testing.T) {
+	a, b := newTestSet(1, 2), newTestSet(2, 3)
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := range 200 {
+				a.Union(b)
+				a.Add(i)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := range 200 {
+				b.Intersect(a)
+				b.Add(i)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestSetAll(t *testing.T) {
+	s := newTestSet(3, 1, 2)
+	var got []int
+	for v := range s.All() {
+		got = append(got, v)
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, []int{1, 2, 3}) {
+		t.Fatalf("All() = %v", got)
+	}
+
+	n := 0
+	for range s.All() {
+		n++
+		if n == 2 {
+			break
+		}
+	}
+	if n != 2 {
+		t.Fatalf("break after %d items", n)
+	}
+	// 提前退出后读锁已释放
+	s.Add(4)
+
+	var empty Set[int]
+	for range empty.All() {
+		t.Fatal("empty set should yield nothing")
+	}
+}
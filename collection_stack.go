@@ -2,40 +2,95 @@ package niu
 
 import (
 	"fmt"
+	"iter"
 	"sync"
 )
 
 type fastStackNode[T any] struct {
 	value *T
-	next  *fastStackNode[T]
+	next  *fastStackNode[T] // 靠近栈底的节点
+	prev  *fastStackNode[T] // 靠近栈顶的节点，用于从栈底移除
 }
 
+// 基于链表的后进先出栈，内部使用读写锁，可在多个协程间共享
+// 默认不限制容量，可通过 SetCapacity 限制
 type FastStack[T any] struct {
-	top  *fastStackNode[T]
-	size int
-	lock sync.RWMutex
+	top      *fastStackNode[T]
+	bottom   *fastStackNode[T]
+	size     int
+	capacity int
+	policy   OverflowPolicy
+	lock     sync.RWMutex
 }
 
+// 限制栈最多容纳 capacity 个元素，capacity <= 0 表示不限制
+// OverflowEvictOldest 移除的是栈底（最早入栈）的元素
+// 当前元素个数已超过 capacity 时：OverflowEvictOldest 立即移除多余的元素，OverflowReject 保留已有元素
+func (stack *FastStack[T]) SetCapacity(capacity int, policy OverflowPolicy) {
+	stack.lock.Lock()
+	defer stack.lock.Unlock()
+	stack.capacity = capacity
+	stack.policy = policy
+	if policy == OverflowEvictOldest {
+		for capacity > 0 && stack.size > capacity {
+			stack.removeBottom()
+		}
+	}
+}
+
+// 返回是否入栈，栈已满且策略为 OverflowReject 时返回 false
 // O(1)
-func (stack *FastStack[T]) Push(val *T) {
+func (stack *FastStack[T]) Push(val *T) bool {
 	stack.lock.Lock()
 	defer stack.lock.Unlock()
-	stack.top = &fastStackNode[T]{value: val, next: stack.top}
-	stack.size++
+	return stack.push(val)
 }
 
+// 返回入栈的元素个数，栈已满且策略为 OverflowReject 时之后的元素不会入栈
 // O(n)
-func (stack *FastStack[T]) PushAll(vals ...T) {
-	l := len(vals)
-	if l == 0 {
-		return
+func (stack *FastStack[T]) PushAll(vals ...T) int {
+	if len(vals) == 0 {
+		return 0
 	}
 	stack.lock.Lock()
 	defer stack.lock.Unlock()
-	for _, v := range vals {
-		stack.top = &fastStackNode[T]{value: &v, next: stack.top}
+	for i, v := range vals {
+		if !stack.push(&v) {
+			return i
+		}
 	}
-	stack.size += l
+	return len(vals)
+}
+
+func (stack *FastStack[T]) push(val *T) bool {
+	if stack.capacity > 0 && stack.size >= stack.capacity {
+		if stack.policy == OverflowReject {
+			return false
+		}
+		stack.removeBottom()
+	}
+	node := &fastStackNode[T]{value: val, next: stack.top}
+	if stack.top != nil {
+		stack.top.prev = node
+	} else {
+		stack.bottom = node
+	}
+	stack.top = node
+	stack.size++
+	return true
+}
+
+func (stack *FastStack[T]) removeBottom() {
+	if stack.bottom == nil {
+		return
+	}
+	stack.bottom = stack.bottom.prev
+	if stack.bottom != nil {
+		stack.bottom.next = nil
+	} else {
+		stack.top = nil
+	}
+	stack.size--
 }
 
 // O(1)
@@ -47,6 +102,11 @@ func (stack *FastStack[T]) Pop() *T {
 	}
 	out := stack.top
 	stack.top = out.next
+	if stack.top != nil {
+		stack.top.prev = nil
+	} else {
+		stack.bottom = nil
+	}
 	stack.size--
 	return out.value
 }
@@ -56,6 +116,7 @@ func (stack *FastStack[T]) Clear() {
 	stack.lock.Lock()
 	defer stack.lock.Unlock()
 	stack.top = nil
+	stack.bottom = nil
 	stack.size = 0
 }
 
@@ -83,6 +144,21 @@ func (stack *FastStack[T]) IsEmpty() bool {
 	return stack.size == 0
 }
 
+// 按后进先出的顺序（top->bottom）遍历，不会出栈，元素与 Pop 返回的指针相同
+// 遍历期间持有读锁，循环体内不能修改该栈，否则会死锁
+func (stack *FastStack[T]) All() iter.Seq[*T] {
+	return func(yield func(*T) bool) {
+		stack.lock.RLock()
+		defer stack.lock.RUnlock()
+
+		for node := stack.top; node != nil; node = node.next {
+			if !yield(node.value) {
+				return
+			}
+		}
+	}
+}
+
 // for test only
 func (stack *FastStack[T]) Print() {
 	fmt.Println("--------start print stack[top->bottom]----------")
@@ -0,0 +1,100 @@
+package niu
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestFastStackConcurrent(t *testing.T) {
+	var s FastStack[int]
+	var wg sync.WaitGroup
+	var popped atomic.Int32
+	for range 4 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := range 500 {
+				s.Push(&i)
+				s.Peek()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for range 400 {
+				if s.Pop() != nil {
+					popped.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if int(popped.Load())+s.Size() != 2000 {
+		t.Fatalf("popped %d + remaining %d != 2000", popped.Load(), s.Size())
+	}
+}
+
+func TestFastStackAll(t *testing.T) {
+	var s FastStack[int]
+	s.PushAll(1, 2, 3)
+	var got []int
+	for v := range s.All() {
+		got = append(got, *v)
+	}
+	if !slices.Equal(got, []int{3, 2, 1}) {
+		t.Fatalf("All() = %v, want top->bottom", got)
+	}
+	if s.Size() != 3 {
+		t.Fatal("All should not pop")
+	}
+
+	got = got[:0]
+	for v := range s.All() {
+		got = append(got, *v)
+		break
+	}
+	if !slices.Equal(got, []int{3}) {
+		t.Fatalf("break got %v", got)
+	}
+	// 提前退出后读锁已释放
+	s.Push(new(int))
+}
+
+func stackValues(s *FastStack[int]) []int {
+	var out []int
+	for v := range s.All() {
+		out = append(out, *v)
+	}
+	return out
+}
+
+func TestFastStackCapacity(t *testing.T) {
+	var s FastStack[int]
+	s.SetCapacity(2, OverflowReject)
+	if n := s.PushAll(1, 2, 3); n != 2 {
+		t.Fatalf("PushAll = %d, want 2", n)
+	}
+	if s.Push(new(int)) {
+		t.Fatal("Push on a full stack should return false")
+	}
+	if got := stackValues(&s); !slices.Equal(got, []int{2, 1}) {
+		t.Fatalf("stack = %v", got)
+	}
+
+	// 移除栈底（最早入栈）的元素
+	s.SetCapacity(2, OverflowEvictOldest)
+	if n := s.PushAll(3, 4); n != 2 {
+		t.Fatalf("PushAll = %d, want 2", n)
+	}
+	if got := stackValues(&s); !slices.Equal(got, []int{4, 3}) {
+		t.Fatalf("stack = %v, want [4 3]", got)
+	}
+	s.SetCapacity(1, OverflowEvictOldest)
+	if got := stackValues(&s); !slices.Equal(got, []int{4}) {
+		t.Fatalf("after shrink = %v", got)
+	}
+	if v := s.Pop(); v == nil || *v != 4 || s.Pop() != nil {
+		t.Fatal("pop after shrink")
+	}
+}
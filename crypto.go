@@ -184,3 +184,134 @@ func NewEd25519CryptorKeyPair() (pubKey, priKey []byte, err error) {
 
 	return public, private, nil
 }
+
+type AesGcmCryptor struct {
+	aead           cipher.AEAD
+	additionalData []byte // 附加数据，参与认证但不加密，加解密双方必须一致
+}
+
+// key 的长度必须为 16、24 或 32 字节，分别对应 AES-128、AES-192、AES-256
+func NewAesGcmCryptor(key []byte, additionalData ...byte) (*AesGcmCryptor, error) {
+	switch len(key) {
+	case 16, 24, 32:
+	default:
+		return nil, errors.New("aes key length must be 16, 24 or 32 bytes")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &AesGcmCryptor{aead: aead, additionalData: additionalData}, nil
+}
+
+// 对指定输入加密，结果为: nonce(12字节) + cipherData + tag
+func (a *AesGcmCryptor) Encrypt(rawData []byte) ([]byte, error) {
+	nonce, err := SecureBytes(a.aead.NonceSize())
+	if err != nil {
+		return nil, err
+	}
+
+	return a.aead.Seal(nonce, nonce, rawData, a.additionalData), nil
+}
+
+func (a *AesGcmCryptor) EncryptToString(rawData []byte) (string, error) {
+	cipherBytes, err := a.Encrypt(rawData)
+	if err != nil {
+		return "", err
+	}
+
+	return Base64Encode(cipherBytes), nil
+}
+
+// 对指定输入解密，输入为: nonce + cipherData + tag。数据被篡改时返回错误
+func (a *AesGcmCryptor) Decrypt(ciphertext []byte) ([]byte, error) {
+	nonceSize := a.aead.NonceSize()
+	if len(ciphertext) < nonceSize+a.aead.Overhead() {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	return a.aead.Open(nil, ciphertext[:nonceSize], ciphertext[nonceSize:], a.additionalData)
+}
+
+func (a *AesGcmCryptor) DecryptFromString(ciphertext string) ([]byte, error) {
+	data, err := Base64Decode(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.Decrypt(data)
+}
+
+// 密钥Id，写在密文的第一个字节
+type KeyID byte
+
+var ErrUnknownKeyID = errors.New("unknown key id")
+
+// 支持密钥轮换的 AES-GCM 加密器，结果为: keyId(1字节) + nonce + cipherData + tag
+// 加密总是使用 primary 对应的密钥；解密时根据密文中的 keyId 选择密钥，
+// 因此在轮换的过渡期内，旧密钥加密的数据仍可解密
+type RotatingCryptor struct {
+	primary  KeyID
+	cryptors map[KeyID]*AesGcmCryptor
+}
+
+// keys 中必须包含 primary，每个密钥的长度要求同 NewAesGcmCryptor
+func NewRotatingCryptor(primary KeyID, keys map[KeyID][]byte) (*RotatingCryptor, error) {
+	if _, ok := keys[primary]; !ok {
+		return nil, errors.New("primary key not found in keys")
+	}
+
+	cryptors := make(map[KeyID]*AesGcmCryptor, len(keys))
+	for id, key := range keys {
+		c, err := NewAesGcmCryptor(key)
+		if err != nil {
+			return nil, err
+		}
+		cryptors[id] = c
+	}
+	return &RotatingCryptor{primary: primary, cryptors: cryptors}, nil
+}
+
+func (r *RotatingCryptor) Encrypt(rawData []byte) ([]byte, error) {
+	cipherData, err := r.cryptors[r.primary].Encrypt(rawData)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte{byte(r.primary)}, cipherData...), nil
+}
+
+func (r *RotatingCryptor) EncryptToString(rawData []byte) (string, error) {
+	cipherBytes, err := r.Encrypt(rawData)
+	if err != nil {
+		return "", err
+	}
+
+	return Base64Encode(cipherBytes), nil
+}
+
+func (r *RotatingCryptor) Decrypt(ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) == 0 {
+		return nil, errors.New("ciphertext too short")
+	}
+	c, ok := r.cryptors[KeyID(ciphertext[0])]
+	if !ok {
+		return nil, ErrUnknownKeyID
+	}
+
+	return c.Decrypt(ciphertext[1:])
+}
+
+func (r *RotatingCryptor) DecryptFromString(ciphertext string) ([]byte, error) {
+	data, err := Base64Decode(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.Decrypt(data)
+}
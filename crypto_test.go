@@ -0,0 +1,148 @@
+package niu
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestAesGcmCryptorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     []byte
+		aad     []byte
+		wantErr bool
+	}{
+		{"aes-128", bytes.Repeat([]byte{1}, 16), nil, false},
+		{"aes-192", bytes.Repeat([]byte{2}, 24), nil, false},
+		{"aes-256 with aad", bytes.Repeat([]byte{3}, 32), []byte("aad"), false},
+		{"short key", bytes.Repeat([]byte{4}, 15), nil, true},
+		{"empty key", nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewAesGcmCryptor(tt.key, tt.aad...)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, plain := range [][]byte{{}, []byte("hello"), bytes.Repeat([]byte{0xAB}, 4096)} {
+				cipherData, err := c.Encrypt(plain)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(cipherData) != len(plain)+12+16 {
+					t.Fatalf("ciphertext length %d for %d bytes", len(cipherData), len(plain))
+				}
+				got, err := c.Decrypt(cipherData)
+				if err != nil || !bytes.Equal(got, plain) {
+					t.Fatalf("decrypt: %v %v", got, err)
+				}
+
+				str, err := c.EncryptToString(plain)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got, err := c.DecryptFromString(str); err != nil || !bytes.Equal(got, plain) {
+					t.Fatalf("decrypt string: %v %v", got, err)
+				}
+			}
+		})
+	}
+}
+
+func TestAesGcmCryptorRejectsTampering(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	c, _ := NewAesGcmCryptor(key, []byte("a")...)
+	cipherData, err := c.Encrypt([]byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 同一明文每次加密的 nonce 不同
+	again, _ := c.Encrypt([]byte("hello"))
+	if bytes.Equal(cipherData, again) {
+		t.Fatal("nonce reused")
+	}
+
+	otherAad, _ := NewAesGcmCryptor(key, []byte("b")...)
+	otherKey, _ := NewAesGcmCryptor(bytes.Repeat([]byte{2}, 32), []byte("a")...)
+	tests := []struct {
+		name   string
+		c      *AesGcmCryptor
+		mutate func([]byte) []byte
+	}{
+		{"flipped bit", c, func(b []byte) []byte { b[len(b)-1] ^= 1; return b }},
+		{"flipped nonce", c, func(b []byte) []byte { b[0] ^= 1; return b }},
+		{"truncated", c, func(b []byte) []byte { return b[:27] }},
+		{"different aad", otherAad, func(b []byte) []byte { return b }},
+		{"different key", otherKey, func(b []byte) []byte { return b }},
+	}
+	for _, tt := range tests {
+		data := tt.mutate(bytes.Clone(cipherData))
+		if _, err := tt.c.Decrypt(data); err == nil {
+			t.Errorf("%s: decrypt should fail", tt.name)
+		}
+	}
+}
+
+func TestRotatingCryptor(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{1}, 32)
+	newKey := bytes.Repeat([]byte{2}, 16)
+	if _, err := NewRotatingCryptor(3, map[KeyID][]byte{1: oldKey}); err == nil {
+		t.Fatal("missing primary should fail")
+	}
+	if _, err := NewRotatingCryptor(1, map[KeyID][]byte{1: oldKey, 2: {1, 2, 3}}); err == nil {
+		t.Fatal("invalid key length should fail")
+	}
+
+	before, err := NewRotatingCryptor(1, map[KeyID][]byte{1: oldKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldData, err := before.Encrypt([]byte("old"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if oldData[0] != 1 {
+		t.Fatalf("key id = %d, want 1", oldData[0])
+	}
+
+	// 轮换到新密钥，旧密钥仍用于解密
+	during, _ := NewRotatingCryptor(2, map[KeyID][]byte{1: oldKey, 2: newKey})
+	newData, err := during.EncryptToString([]byte("new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, _ := NewRotatingCryptor(2, map[KeyID][]byte{2: newKey})
+
+	tests := []struct {
+		name    string
+		c       *RotatingCryptor
+		data    []byte
+		want    string
+		wantErr error
+	}{
+		{"old data during rotation", during, oldData, "old", nil},
+		{"old data after rotation", after, oldData, "", ErrUnknownKeyID},
+	}
+	for _, tt := range tests {
+		got, err := tt.c.Decrypt(tt.data)
+		if !errors.Is(err, tt.wantErr) || string(got) != tt.want {
+			t.Errorf("%s: got %q %v, want %q %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+	if _, err := during.Decrypt(nil); err == nil {
+		t.Error("empty ciphertext should fail")
+	}
+	for _, c := range []*RotatingCryptor{during, after} {
+		if got, err := c.DecryptFromString(newData); err != nil || string(got) != "new" {
+			t.Fatalf("new data: %q %v", got, err)
+		}
+	}
+}
@@ -57,11 +57,19 @@ func (d *DistributeId) Close() {
 	clear(d.idGenerators)
 }
 
+// NextBuffered 每次从 Redis 预取的Id数量
+const defaultIdBufferSize = 100
+
 type IdGenerator struct {
 	client        *redis.Client
 	onceInitIdFac sync.Once
 	key           string
 	start         int
+
+	bufMutex   sync.Mutex
+	bufferSize int
+	bufNext    int // 本地缓冲中下一个可用的Id
+	bufEnd     int // 本地缓冲的结束位置（不含）
 }
 
 func (d *IdGenerator) init(ctx context.Context) error {
@@ -80,3 +88,46 @@ func (c *IdGenerator) Next(ctx context.Context) (int, error) {
 	}
 	return int(res), nil
 }
+
+// 一次预留 n 个连续的Id，返回的范围为 [start, end)，只需一次 Redis 请求
+func (c *IdGenerator) NextBatch(ctx context.Context, n int) (start, end int, err error) {
+	if n <= 0 {
+		return -1, -1, ErrInvalidDistributeIdParams
+	}
+	res, err := c.client.IncrBy(ctx, c.key, int64(n)).Result()
+	if err != nil {
+		return -1, -1, err
+	}
+	end = int(res) + 1
+	return end - n, end, nil
+}
+
+// 设置 NextBuffered 每次预取的Id数量，size <= 0 时使用默认值
+func (c *IdGenerator) SetBufferSize(size int) {
+	c.bufMutex.Lock()
+	defer c.bufMutex.Unlock()
+	c.bufferSize = size
+}
+
+// 从本地缓冲中获取Id，缓冲用完时一次性从 Redis 预取一批
+// 多个进程间Id仍然唯一，但不再严格递增；进程退出时未用完的Id会被丢弃
+func (c *IdGenerator) NextBuffered(ctx context.Context) (int, error) {
+	c.bufMutex.Lock()
+	defer c.bufMutex.Unlock()
+
+	if c.bufNext >= c.bufEnd {
+		size := c.bufferSize
+		if size <= 0 {
+			size = defaultIdBufferSize
+		}
+		start, end, err := c.NextBatch(ctx, size)
+		if err != nil {
+			return -1, err
+		}
+		c.bufNext, c.bufEnd = start, end
+	}
+
+	id := c.bufNext
+	c.bufNext++
+	return id, nil
+}
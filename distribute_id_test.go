@@ -0,0 +1,88 @@
+package niu
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestIdGenerator(t *testing.T, start int) *IdGenerator {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	ctx := context.Background()
+	d, err := NewDistributeId(ctx, &redis.Options{Addr: mr.Addr()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(d.Close)
+	g, err := d.NewGenerator(ctx, "id", start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func TestIdGeneratorNextBatch(t *testing.T) {
+	ctx := context.Background()
+	g := newTestIdGenerator(t, 100)
+
+	if id, _ := g.Next(ctx); id != 101 {
+		t.Fatalf("Next = %d, want 101", id)
+	}
+	start, end, err := g.NextBatch(ctx, 10)
+	if err != nil || start != 102 || end != 112 {
+		t.Fatalf("NextBatch = [%d, %d) %v, want [102, 112)", start, end, err)
+	}
+	if id, _ := g.Next(ctx); id != 112 {
+		t.Fatalf("Next after batch = %d, want 112", id)
+	}
+	for _, n := range []int{0, -1} {
+		if _, _, err := g.NextBatch(ctx, n); !errors.Is(err, ErrInvalidDistributeIdParams) {
+			t.Fatalf("NextBatch(%d) = %v", n, err)
+		}
+	}
+}
+
+func TestIdGeneratorNextBuffered(t *testing.T) {
+	ctx := context.Background()
+	g := newTestIdGenerator(t, 0)
+	g.SetBufferSize(3)
+
+	var mutex sync.Mutex
+	seen := map[int]bool{}
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 25 {
+				id, err := g.NextBuffered(ctx)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mutex.Lock()
+				if seen[id] {
+					t.Errorf("duplicate id %d", id)
+				}
+				seen[id] = true
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	// 同一个 IdGenerator 分配的 Id 连续、不重复
+	for id := 1; id <= 100; id++ {
+		if !seen[id] {
+			t.Fatalf("missing id %d", id)
+		}
+	}
+	// 缓冲中剩余 101、102，Next 不会与其重复
+	if id, _ := g.Next(ctx); id != 103 {
+		t.Fatalf("Next = %d, want 103 after 34 prefetches of 3", id)
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -19,6 +21,56 @@ var (
 	luaRefresh = redis.NewScript(`if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("pexpire", KEYS[1], ARGV[2]) else return 0 end`)
 	luaRelease = redis.NewScript(`if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) else return 0 end`)
 	// luaPTTL    = redis.NewScript(`if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("pttl", KEYS[1]) else return -3 end`)
+
+	// 公平锁：KEYS[1] 锁，KEYS[2] 等待队列(zset，按首次排队时间排序)，KEYS[3] 等待者租约(zset，score 为租约到期时间)
+	// ARGV[1] owner，ARGV[2] 锁的ttl(ms)，ARGV[3] 当前时间(ms)，ARGV[4] 等待者租约ttl(ms)
+	// 等待者每次尝试都会续期自己的租约，租约过期的队首等待者（如进程崩溃）会被移出队列
+	// 访问的 key 都通过 KEYS 传入，以便 Redis Cluster 校验它们位于同一槽位
+	luaFairAcquire = redis.NewScript(`
+local now = tonumber(ARGV[3])
+redis.call("zadd", KEYS[2], "NX", now, ARGV[1])
+redis.call("zadd", KEYS[3], now + tonumber(ARGV[4]), ARGV[1])
+redis.call("pexpire", KEYS[2], ARGV[4])
+redis.call("pexpire", KEYS[3], ARGV[4])
+while true do
+	local head = redis.call("zrange", KEYS[2], 0, 0)[1]
+	if head == ARGV[1] then break end
+	local expireAt = redis.call("zscore", KEYS[3], head)
+	if expireAt and tonumber(expireAt) > now then return 0 end
+	redis.call("zrem", KEYS[2], head)
+	redis.call("zrem", KEYS[3], head)
+end
+if redis.call("set", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	redis.call("zrem", KEYS[2], ARGV[1])
+	redis.call("zrem", KEYS[3], ARGV[1])
+	return 1
+end
+return 0`)
+	// 可重入锁：KEYS[1] 锁，KEYS[2] 重入次数，ARGV[1] owner，ARGV[2] ttl(ms)
+	// 获取成功时返回当前的重入次数，失败返回0
+	luaReentrantAcquire = redis.NewScript(`
+if redis.call("set", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	redis.call("set", KEYS[2], 1, "PX", ARGV[2])
+	return 1
+end
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	local n = redis.call("incr", KEYS[2])
+	redis.call("pexpire", KEYS[1], ARGV[2])
+	redis.call("pexpire", KEYS[2], ARGV[2])
+	return n
+end
+return 0`)
+	// 返回剩余的重入次数，为0时锁被删除；锁不属于 owner 时返回-1
+	luaReentrantRelease = redis.NewScript(`
+if redis.call("get", KEYS[1]) ~= ARGV[1] then return -1 end
+local n = redis.call("decr", KEYS[2])
+if n <= 0 then
+	redis.call("del", KEYS[1], KEYS[2])
+	return 0
+end
+return n`)
+	luaReentrantRefresh = redis.NewScript(`if redis.call("get", KEYS[1]) == ARGV[1] then redis.call("pexpire", KEYS[2], ARGV[2]) return redis.call("pexpire", KEYS[1], ARGV[2]) else return 0 end`)
+	luaFairCancel       = redis.NewScript(`redis.call("zrem", KEYS[2], ARGV[1]) return redis.call("zrem", KEYS[1], ARGV[1])`)
 )
 
 type DistributeLockOptions struct {
@@ -26,13 +78,35 @@ type DistributeLockOptions struct {
 	Owner         string
 	Ttl           time.Duration
 	RetryStrategy RetryStrategy
+	// 公平模式：按排队的先后顺序获取锁，避免竞争激烈时部分调用方一直拿不到锁
+	// 只有队首的等待者才能获取锁，持锁者释放后，队首要到下一次重试时才会拿到锁，
+	// 因此相比非公平模式平均会多出约半个重试间隔的延迟。重试间隔应小于 2*Ttl，否则会被当作已失效的等待者
+	// 租约按调用方的本地时间计算，各节点的时钟偏差应远小于 2*Ttl
+	Fair bool
+	// 可重入模式：持有锁的 owner 再次加锁时重入次数加1，而不是加锁失败
+	// Release 每次将重入次数减1，减到0时才真正释放锁。同时设置 Fair 时以 Reentrant 为准
+	Reentrant bool
 }
 
+// 每次加锁结束后调用：duration 为加锁耗时，retries 为重试次数（第一次尝试即成功为0），acquired 为是否获取成功
+// 可用于统计锁的竞争情况，调用发生在加锁的协程中，不应执行耗时操作
+type LockObserver func(resource string, duration time.Duration, retries int, acquired bool)
+
 type DistributeLocker struct {
 	mutex                sync.RWMutex
 	redisClient          *redis.Client
 	defaultTtl           time.Duration
 	defaultRetryStrategy RetryStrategy
+	observer             atomic.Pointer[LockObserver]
+}
+
+// 设置加锁的观察者，传入 nil 取消；未设置时不会有额外开销
+func (l *DistributeLocker) SetObserver(o LockObserver) {
+	if o == nil {
+		l.observer.Store(nil)
+		return
+	}
+	l.observer.Store(&o)
 }
 
 func NewDistributeLocker(ctx context.Context, opt *redis.Options, ttl time.Duration, retryStrategy RetryStrategy) (*DistributeLocker, error) {
@@ -63,8 +137,39 @@ func (l *DistributeLocker) Lock(ctx context.Context, resource string, owner stri
 	})
 }
 
+// 尝试在指定资源上加锁，只尝试一次，锁被占用时立即返回 false，不会重试
+func (l *DistributeLocker) TryLock(ctx context.Context, resource string, owner string) (*DistributeLock, bool, error) {
+	var start time.Time
+	obs := l.observer.Load()
+	if obs != nil {
+		start = time.Now()
+	}
+	ok, err := l.redisClient.SetNX(ctx, resource, owner, l.defaultTtl).Result()
+	if obs != nil {
+		(*obs)(resource, time.Since(start), 0, err == nil && ok)
+	}
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return &DistributeLock{l.redisClient, resource, owner, l.defaultTtl, false}, true, nil
+}
+
 // 在指定资源上加锁，默认5s
+// ctx 未设置截止时间时，最多重试到 ttl 后为止
 func (l *DistributeLocker) LockWithOptions(ctx context.Context, opt *DistributeLockOptions) (*DistributeLock, error) {
+	obs := l.observer.Load()
+	if obs == nil {
+		lock, _, err := l.lockWithOptions(ctx, opt)
+		return lock, err
+	}
+	start := time.Now()
+	lock, retries, err := l.lockWithOptions(ctx, opt)
+	(*obs)(opt.Resource, time.Since(start), retries, err == nil)
+	return lock, err
+}
+
+// 额外返回重试次数
+func (l *DistributeLocker) lockWithOptions(ctx context.Context, opt *DistributeLockOptions) (*DistributeLock, int, error) {
 	ttl := l.defaultTtl
 
 	if opt.Ttl > 0 {
@@ -74,6 +179,10 @@ func (l *DistributeLocker) LockWithOptions(ctx context.Context, opt *DistributeL
 	if opt.RetryStrategy != nil {
 		retryStrategy = opt.RetryStrategy
 	}
+	// 有状态的策略每次加锁都使用独立的实例
+	if s, ok := retryStrategy.(StatefulRetryStrategy); ok {
+		retryStrategy = s.Clone()
+	}
 	// make sure we don't retry forever
 	if _, ok := ctx.Deadline(); !ok {
 		var cancel context.CancelFunc
@@ -81,34 +190,91 @@ func (l *DistributeLocker) LockWithOptions(ctx context.Context, opt *DistributeL
 		defer cancel()
 	}
 
-	for {
-		ok, err := l.redisClient.SetNX(ctx, opt.Resource, opt.Owner, ttl).Result()
+	for retries := 0; ; retries++ {
+		ok, err := l.tryAcquire(ctx, opt, ttl)
 		if err != nil {
-			return nil, err
+			l.cancelFair(ctx, opt)
+			return nil, retries, err
 		} else if ok {
-			return &DistributeLock{l.redisClient, opt.Resource, opt.Owner}, nil
+			return &DistributeLock{l.redisClient, opt.Resource, opt.Owner, ttl, opt.Reentrant}, retries, nil
 		}
 		// time.Sleep(1 * time.Second) // mock lock process
 
 		// retry
 		backoff := retryStrategy.Next()
 		if backoff <= time.Duration(0) {
-			return nil, ErrLockFailed
+			l.cancelFair(ctx, opt)
+			return nil, retries, ErrLockFailed
 		}
 		delay := time.After(backoff)
 
 		select {
 		case <-ctx.Done():
-			return nil, ErrLockFailed
+			l.cancelFair(ctx, opt)
+			return nil, retries, ErrLockFailed
 		case <-delay:
 		}
 	}
 }
 
+func (l *DistributeLocker) tryAcquire(ctx context.Context, opt *DistributeLockOptions, ttl time.Duration) (bool, error) {
+	if opt.Reentrant {
+		ttlVal := strconv.FormatInt(int64(ttl/time.Millisecond), 10)
+		n, err := luaReentrantAcquire.Run(ctx, l.redisClient, []string{opt.Resource, reentrantCountKey(opt.Resource)}, opt.Owner, ttlVal).Int64()
+		if err != nil {
+			return false, err
+		}
+		return n > 0, nil
+	}
+	if !opt.Fair {
+		return l.redisClient.SetNX(ctx, opt.Resource, opt.Owner, ttl).Result()
+	}
+
+	ttlMs := int64(ttl / time.Millisecond)
+	res, err := luaFairAcquire.Run(ctx, l.redisClient,
+		[]string{opt.Resource, fairQueueKey(opt.Resource), fairLeaseKey(opt.Resource)},
+		opt.Owner, ttlMs, time.Now().UnixMilli(), 2*ttlMs,
+	).Int64()
+	if err != nil {
+		return false, err
+	}
+	return res == 1, nil
+}
+
+// 放弃加锁时退出等待队列，避免阻塞后面的等待者
+func (l *DistributeLocker) cancelFair(ctx context.Context, opt *DistributeLockOptions) {
+	if !opt.Fair || opt.Reentrant {
+		return
+	}
+	// ctx 可能已经取消，这里仍需执行清理
+	luaFairCancel.Run(context.WithoutCancel(ctx), l.redisClient,
+		[]string{fairQueueKey(opt.Resource), fairLeaseKey(opt.Resource)},
+		opt.Owner,
+	)
+}
+
+func fairQueueKey(resource string) string { return lockSubKey(resource, ":queue") }
+
+func fairLeaseKey(resource string) string { return lockSubKey(resource, ":lease") }
+
+func reentrantCountKey(resource string) string { return lockSubKey(resource, ":reentrant") }
+
+// 锁的辅助 key，与锁本身位于 Redis Cluster 的同一槽位，脚本才能同时访问
+// resource 已带 hash tag 时沿用该 tag，否则以整个 resource 作为 tag，其槽位与 resource 相同
+// resource 含有 { 或 } 却不是有效的 hash tag 时无法保证，应使用 HashTagKey 生成
+func lockSubKey(resource, suffix string) string {
+	if KeyHashTag(resource) != resource || strings.ContainsAny(resource, "{}") {
+		return resource + suffix
+	}
+	return "{" + resource + "}" + suffix
+}
+
 type DistributeLock struct {
-	client   *redis.Client
-	resource string
-	owner    string
+	client    *redis.Client
+	resource  string
+	owner     string
+	ttl       time.Duration
+	reentrant bool
 }
 
 func (i *DistributeLock) Refresh(ctx context.Context, ttl time.Duration) error {
@@ -116,7 +282,13 @@ func (i *DistributeLock) Refresh(ctx context.Context, ttl time.Duration) error {
 		return nil
 	}
 	ttlVal := strconv.FormatInt(int64(ttl/time.Millisecond), 10)
-	status, err := luaRefresh.Run(ctx, i.client, []string{i.resource}, i.owner, ttlVal).Result()
+	var status any
+	var err error
+	if i.reentrant {
+		status, err = luaReentrantRefresh.Run(ctx, i.client, []string{i.resource, reentrantCountKey(i.resource)}, i.owner, ttlVal).Result()
+	} else {
+		status, err = luaRefresh.Run(ctx, i.client, []string{i.resource}, i.owner, ttlVal).Result()
+	}
 	if err != nil {
 		return err
 	} else if status == int64(1) {
@@ -125,11 +297,58 @@ func (i *DistributeLock) Refresh(ctx context.Context, ttl time.Duration) error {
 	return ErrLockNotHeld
 }
 
+// 启动看门狗：后台每隔 interval 将锁的有效期续为加锁时的 ttl，直到调用 stop 或 ctx 被取消
+// 续期时发现锁已丢失(ErrLockNotHeld)或出错，会停止续期并将错误写入返回的通道，之后通道被关闭
+// stop 可重复调用，返回时保证不会再有续期操作，释放锁之前应先调用 stop
+func (i *DistributeLock) StartWatchdog(ctx context.Context, interval time.Duration) (stop func(), errChan <-chan error) {
+	errs := make(chan error, 1)
+	stopChan := make(chan Empty)
+	done := make(chan Empty)
+
+	go func() {
+		defer close(done)
+		defer close(errs)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopChan:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := i.Refresh(ctx, i.ttl); err != nil {
+					if ctx.Err() == nil {
+						errs <- err
+					}
+					return
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	stop = func() {
+		once.Do(func() { close(stopChan) })
+		<-done
+	}
+	return stop, errs
+}
+
 // 释放获取的锁
 func (i *DistributeLock) Release(ctx context.Context) error {
 	if i == nil {
 		return nil
 	}
+	if i.reentrant {
+		n, err := luaReentrantRelease.Run(ctx, i.client, []string{i.resource, reentrantCountKey(i.resource)}, i.owner).Int64()
+		if err != nil {
+			return err
+		} else if n < 0 {
+			return ErrLockNotHeld
+		}
+		return nil
+	}
 	res, err := luaRelease.Run(ctx, i.client, []string{i.resource}, i.owner).Result()
 	if err == redis.Nil {
 		return ErrLockNotHeld
@@ -0,0 +1,268 @@
+package niu
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestLocker(t *testing.T, ttl time.Duration, retryStrategy RetryStrategy) (*DistributeLocker, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	l, err := NewDistributeLocker(context.Background(), &redis.Options{Addr: mr.Addr()}, ttl, retryStrategy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(l.Close)
+	return l, mr
+}
+
+func TestFairLockOrder(t *testing.T) {
+	ctx := context.Background()
+	l, mr := newTestLocker(t, 2*time.Second, LinearRetryStrategy(10*time.Millisecond))
+	holder, err := l.LockWithOptions(ctx, &DistributeLockOptions{Resource: "r", Owner: "holder", Fair: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mutex sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+	for i, owner := range []string{"a", "b", "c"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lock, err := l.LockWithOptions(ctx, &DistributeLockOptions{Resource: "r", Owner: owner, Fair: true})
+			if err != nil {
+				t.Error(owner, err)
+				return
+			}
+			mutex.Lock()
+			order = append(order, owner)
+			mutex.Unlock()
+			time.Sleep(20 * time.Millisecond)
+			lock.Release(ctx)
+		}()
+		// 等待该等待者进入队列后再启动下一个
+		waitFor(t, func() bool {
+			members, _ := mr.ZMembers(fairQueueKey("r"))
+			return len(members) == i+1
+		})
+	}
+	holder.Release(ctx)
+	wg.Wait()
+
+	if len(order) != 3 || order[0] != "a" || order[1] != "b" || order[2] != "c" {
+		t.Fatalf("order = %v, want [a b c]", order)
+	}
+	if mr.Exists(fairQueueKey("r")) {
+		t.Fatal("queue not cleaned up")
+	}
+}
+
+func TestFairLockQueueCleanup(t *testing.T) {
+	ctx := context.Background()
+	l, mr := newTestLocker(t, 2*time.Second, LinearRetryStrategy(10*time.Millisecond))
+	holder, err := l.LockWithOptions(ctx, &DistributeLockOptions{Resource: "r", Owner: "holder", Fair: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 超时放弃的等待者退出队列
+	timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := l.LockWithOptions(timeoutCtx, &DistributeLockOptions{Resource: "r", Owner: "gave-up", Fair: true}); !errors.Is(err, ErrLockFailed) {
+		t.Fatalf("want ErrLockFailed, got %v", err)
+	}
+	if members, _ := mr.ZMembers(fairQueueKey("r")); len(members) != 0 {
+		t.Fatalf("queue = %v, want empty", members)
+	}
+
+	// 崩溃的等待者：在队首但租约已不存在，会被跳过
+	mr.ZAdd(fairQueueKey("r"), 0, "crashed")
+	holder.Release(ctx)
+	lock, err := l.LockWithOptions(ctx, &DistributeLockOptions{Resource: "r", Owner: "next", Fair: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := mr.Get("r"); v != "next" {
+		t.Fatalf("lock owner = %q", v)
+	}
+	lock.Release(ctx)
+}
+
+// 脚本访问的 key 必须与锁位于同一槽位，Redis Cluster 才能执行
+func TestLockSubKeySlot(t *testing.T) {
+	for _, resource := range []string{"r", "order:1", HashTagKey("user:1", "lock")} {
+		for _, key := range []string{fairQueueKey(resource), fairLeaseKey(resource), reentrantCountKey(resource)} {
+			if KeySlot(key) != KeySlot(resource) {
+				t.Errorf("%q is in slot %d, lock %q in slot %d", key, KeySlot(key), resource, KeySlot(resource))
+			}
+		}
+	}
+	if got := fairQueueKey(HashTagKey("user:1", "lock")); got != "{user:1}:lock:queue" {
+		t.Errorf("tagged resource queue key = %q", got)
+	}
+}
+
+// 队首等待者的租约未过期时，其他等待者不能越过它
+func TestFairLockWaitsForLiveHead(t *testing.T) {
+	ctx := context.Background()
+	l, mr := newTestLocker(t, time.Second, NoRetry())
+	now := time.Now().UnixMilli()
+	mr.ZAdd(fairQueueKey("r"), float64(now-1000), "head")
+	mr.ZAdd(fairLeaseKey("r"), float64(now+60000), "head")
+
+	if _, err := l.LockWithOptions(ctx, &DistributeLockOptions{Resource: "r", Owner: "next", Fair: true}); !errors.Is(err, ErrLockFailed) {
+		t.Fatalf("skipped a live head: %v", err)
+	}
+	// 租约过期后被移出队列
+	mr.ZAdd(fairLeaseKey("r"), float64(now-1), "head")
+	if _, err := l.LockWithOptions(ctx, &DistributeLockOptions{Resource: "r", Owner: "next", Fair: true}); err != nil {
+		t.Fatalf("after head lease expired: %v", err)
+	}
+	if members, _ := mr.ZMembers(fairLeaseKey("r")); len(members) != 0 {
+		t.Fatalf("leases = %v, want empty", members)
+	}
+}
+
+func TestReentrantLock(t *testing.T) {
+	ctx := context.Background()
+	l, mr := newTestLocker(t, 2*time.Second, NoRetry())
+	opt := &DistributeLockOptions{Resource: "r", Owner: "a", Reentrant: true}
+
+	first, err := l.LockWithOptions(ctx, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := l.LockWithOptions(ctx, opt)
+	if err != nil {
+		t.Fatalf("reenter: %v", err)
+	}
+	if v, _ := mr.Get(reentrantCountKey("r")); v != "2" {
+		t.Fatalf("reentrant count = %q, want 2", v)
+	}
+	if _, err := l.LockWithOptions(ctx, &DistributeLockOptions{Resource: "r", Owner: "b", Reentrant: true}); !errors.Is(err, ErrLockFailed) {
+		t.Fatalf("other owner: %v, want ErrLockFailed", err)
+	}
+
+	if err := second.Refresh(ctx, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if mr.TTL("r") != 5*time.Second || mr.TTL(reentrantCountKey("r")) != 5*time.Second {
+		t.Fatalf("refresh ttl = %v %v", mr.TTL("r"), mr.TTL(reentrantCountKey("r")))
+	}
+
+	if err := second.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !mr.Exists("r") {
+		t.Fatal("released before the count reached zero")
+	}
+	if err := first.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if mr.Exists("r") || mr.Exists(reentrantCountKey("r")) {
+		t.Fatal("lock not released")
+	}
+	if err := first.Release(ctx); !errors.Is(err, ErrLockNotHeld) {
+		t.Fatalf("release twice: %v, want ErrLockNotHeld", err)
+	}
+
+	// 过期后其他 owner 可以获取
+	if _, err := l.LockWithOptions(ctx, opt); err != nil {
+		t.Fatal(err)
+	}
+	mr.FastForward(3 * time.Second)
+	if _, err := l.LockWithOptions(ctx, &DistributeLockOptions{Resource: "r", Owner: "b", Reentrant: true}); err != nil {
+		t.Fatalf("after expiry: %v", err)
+	}
+}
+
+// 重试 n 次后放弃
+type countedRetry struct {
+	n       int
+	backoff time.Duration
+}
+
+func (r *countedRetry) Next() time.Duration {
+	if r.n <= 0 {
+		return 0
+	}
+	r.n--
+	return r.backoff
+}
+
+func TestLockObserver(t *testing.T) {
+	ctx := context.Background()
+	l, _ := newTestLocker(t, 2*time.Second, NoRetry())
+	type event struct {
+		resource string
+		duration time.Duration
+		retries  int
+		acquired bool
+	}
+	var events []event
+	l.SetObserver(func(resource string, duration time.Duration, retries int, acquired bool) {
+		events = append(events, event{resource, duration, retries, acquired})
+	})
+	last := func() event {
+		t.Helper()
+		if len(events) == 0 {
+			t.Fatal("observer not called")
+		}
+		return events[len(events)-1]
+	}
+
+	holder, err := l.Lock(ctx, "r", "holder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := last(); e.resource != "r" || e.retries != 0 || !e.acquired {
+		t.Fatalf("uncontended lock: %+v", e)
+	}
+
+	// 锁被占用时每次重试都计数，重试用尽后失败
+	_, err = l.LockWithOptions(ctx, &DistributeLockOptions{Resource: "r", Owner: "b", RetryStrategy: &countedRetry{3, 10 * time.Millisecond}})
+	if !errors.Is(err, ErrLockFailed) {
+		t.Fatalf("contended lock: %v, want ErrLockFailed", err)
+	}
+	if e := last(); e.retries != 3 || e.acquired || e.duration < 30*time.Millisecond {
+		t.Fatalf("contended lock: %+v, want 3 retries over at least 30ms", e)
+	}
+
+	if _, ok, _ := l.TryLock(ctx, "r", "b"); ok {
+		t.Fatal("TryLock should fail while held")
+	}
+	if e := last(); e.retries != 0 || e.acquired {
+		t.Fatalf("TryLock: %+v", e)
+	}
+
+	// 持锁者中途释放，重试后获取成功
+	go func() {
+		time.Sleep(35 * time.Millisecond)
+		holder.Release(ctx)
+	}()
+	lock, err := l.LockWithOptions(ctx, &DistributeLockOptions{Resource: "r", Owner: "b", RetryStrategy: LinearRetryStrategy(10 * time.Millisecond)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := last(); e.retries < 1 || !e.acquired {
+		t.Fatalf("lock after release: %+v, want retries and acquired", e)
+	}
+	lock.Release(ctx)
+
+	l.SetObserver(nil)
+	n := len(events)
+	if _, err := l.Lock(ctx, "r", "c"); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != n {
+		t.Fatal("observer called after it was removed")
+	}
+}
@@ -0,0 +1,124 @@
+package niu
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// 滑动窗口：KEYS[1] 窗口内的请求(zset，score为请求时间ms)，ARGV[1] 窗口(ms)，ARGV[2] 限制次数，ARGV[3] 本次请求的唯一标识
+// 使用 Redis 服务器时间，避免各节点时钟不一致
+// 返回 {是否允许, 剩余次数, 距最早的请求移出窗口的时间(ms)}
+var luaSlidingWindow = redis.NewScript(`
+local t = redis.call("time")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+redis.call("zremrangebyscore", KEYS[1], "-inf", now - window)
+local count = redis.call("zcard", KEYS[1])
+if count < limit then
+	redis.call("zadd", KEYS[1], now, ARGV[3])
+	redis.call("pexpire", KEYS[1], window)
+	return {1, limit - count - 1, 0}
+end
+local oldest = redis.call("zrange", KEYS[1], 0, 0, "WITHSCORES")
+local retryAfter = window
+if oldest[2] then retryAfter = tonumber(oldest[2]) + window - now end
+return {0, 0, retryAfter}`)
+
+// 基于 Redis 的分布式限流器，多个节点共享同一限额
+type DistributeRateLimiter struct {
+	client  *redis.Client
+	onError func(ctx *gin.Context, err error)
+}
+
+// DistributeRateLimiter 的可选配置
+type RateLimiterOption func(*DistributeRateLimiter)
+
+// 中间件访问 Redis 出错时调用，可用于记录日志或上报指标，未设置时忽略错误。无论是否设置，请求都会被放行
+func WithRateLimitErrorHandler(fn func(ctx *gin.Context, err error)) RateLimiterOption {
+	return func(l *DistributeRateLimiter) {
+		l.onError = fn
+	}
+}
+
+func NewDistributeRateLimiter(ctx context.Context, opt *redis.Options, opts ...RateLimiterOption) (*DistributeRateLimiter, error) {
+	client := redis.NewClient(opt)
+	_, err := client.Ping(ctx).Result()
+	if err != nil {
+		return nil, err
+	}
+	l := &DistributeRateLimiter{client: client}
+	for _, o := range opts {
+		o(l)
+	}
+	return l, nil
+}
+
+func (l *DistributeRateLimiter) Close() error {
+	return l.client.Close()
+}
+
+// 判断 key 在最近 window 时间内的请求是否未超过 limit 次，允许时计入本次请求
+// remaining 为本次之后窗口内还可以请求的次数
+func (l *DistributeRateLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (allowed bool, remaining int, err error) {
+	allowed, remaining, _, err = l.allow(ctx, key, limit, window)
+	return
+}
+
+// 额外返回被拒绝时需要等待多久才可能再次被允许
+func (l *DistributeRateLimiter) allow(ctx context.Context, key string, limit int, window time.Duration) (bool, int, time.Duration, error) {
+	res, err := luaSlidingWindow.Run(ctx, l.client, []string{key}, window.Milliseconds(), limit, NewUUIDWithoutDash()).Int64Slice()
+	if err != nil {
+		return false, 0, 0, err
+	}
+	return res[0] == 1, int(res[1]), time.Duration(res[2]) * time.Millisecond, nil
+}
+
+// 限流中间件，每个 key 在 window 时间内最多请求 limit 次
+// keyFn 为 nil 或返回空字符串时按客户端 IP 限流，如可返回登录用户的 id，未登录时返回空字符串
+// Redis 中的 key 包含 limit 与 window，不同配置的中间件（如不同的路由组）各自计数，互不影响
+// 响应头 X-RateLimit-Remaining 为剩余次数；超出限制时返回 429 并带上 Retry-After（秒）
+// Redis 出错时放行请求，避免限流器故障导致服务不可用，错误交给 WithRateLimitErrorHandler 设置的处理函数
+func (l *DistributeRateLimiter) RateLimitMiddleware(limit int, window time.Duration, keyFn func(*gin.Context) string) gin.HandlerFunc {
+	prefix := fmt.Sprintf("ratelimit:%d:%d:", limit, window.Milliseconds())
+	return func(ctx *gin.Context) {
+		var key string
+		if keyFn != nil {
+			if k := keyFn(ctx); k != "" {
+				key = prefix + "key:" + k
+			}
+		}
+		if key == "" {
+			ip := GetClientIp(ctx)
+			if ip == "" {
+				ctx.AbortWithStatus(http.StatusBadRequest)
+				return
+			}
+			key = prefix + "ip:" + ip
+		}
+
+		allowed, remaining, retryAfter, err := l.allow(ctx, key, limit, window)
+		if err != nil {
+			if l.onError != nil {
+				l.onError(ctx, err)
+			}
+			ctx.Next()
+			return
+		}
+		ctx.Header("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		if !allowed {
+			// 向上取整，避免客户端过早重试
+			seconds := int((retryAfter + time.Second - 1) / time.Second)
+			ctx.Header("Retry-After", strconv.Itoa(max(seconds, 1)))
+			ctx.AbortWithStatus(http.StatusTooManyRequests)
+			return
+		}
+		ctx.Next()
+	}
+}
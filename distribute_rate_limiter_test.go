@@ -0,0 +1,177 @@
+package niu
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestRateLimiter(t *testing.T, opts ...RateLimiterOption) (*DistributeRateLimiter, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	l, err := NewDistributeRateLimiter(context.Background(), &redis.Options{Addr: mr.Addr()}, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l, mr
+}
+
+func TestDistributeRateLimiterAllow(t *testing.T) {
+	l, _ := newTestRateLimiter(t)
+	ctx := context.Background()
+	for i, want := range []struct {
+		allowed   bool
+		remaining int
+	}{{true, 2}, {true, 1}, {true, 0}, {false, 0}} {
+		allowed, remaining, err := l.Allow(ctx, "k", 3, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if allowed != want.allowed || remaining != want.remaining {
+			t.Fatalf("request %d: got %v/%d, want %v/%d", i, allowed, remaining, want.allowed, want.remaining)
+		}
+	}
+}
+
+// 脚本使用 Redis 服务器时间，通过 miniredis 的时间推进窗口
+func TestDistributeRateLimiterWindowBoundary(t *testing.T) {
+	l, mr := newTestRateLimiter(t)
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		at             time.Duration // 相对 start 的时间
+		wantAllowed    bool
+		wantRetryAfter time.Duration
+	}{
+		{0, true, 0},
+		{4 * time.Second, true, 0},
+		{5 * time.Second, false, 5 * time.Second},
+		{9 * time.Second, false, time.Second},
+		{10*time.Second - time.Millisecond, false, time.Millisecond},
+		{10*time.Second + time.Millisecond, true, 0}, // 第一个请求移出窗口
+		{10*time.Second + time.Millisecond, false, 4*time.Second - time.Millisecond},
+		{14 * time.Second, true, 0}, // 第二个请求移出窗口
+	}
+	for i, tt := range tests {
+		mr.SetTime(start.Add(tt.at))
+		allowed, _, retryAfter, err := l.allow(ctx, "k", 2, 10*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if allowed != tt.wantAllowed || retryAfter != tt.wantRetryAfter {
+			t.Fatalf("request %d at %v: got %v/%v, want %v/%v", i, tt.at, allowed, retryAfter, tt.wantAllowed, tt.wantRetryAfter)
+		}
+	}
+}
+
+func TestRateLimitMiddlewareRetryAfterShrinks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	l, mr := newTestRateLimiter(t)
+	r := gin.New()
+	r.Use(l.RateLimitMiddleware(1, 10*time.Second, nil))
+	r.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		at             time.Duration
+		wantCode       int
+		wantRetryAfter string
+	}{
+		{0, http.StatusOK, ""},
+		{time.Second, http.StatusTooManyRequests, "9"},
+		{6500 * time.Millisecond, http.StatusTooManyRequests, "4"},
+		{9999 * time.Millisecond, http.StatusTooManyRequests, "1"},
+		{10001 * time.Millisecond, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		mr.SetTime(start.Add(tt.at))
+		w := doRateLimitRequest(r, "/", "", "1.1.1.1")
+		if w.Code != tt.wantCode || w.Header().Get("Retry-After") != tt.wantRetryAfter {
+			t.Fatalf("at %v: status %d Retry-After %q, want %d %q",
+				tt.at, w.Code, w.Header().Get("Retry-After"), tt.wantCode, tt.wantRetryAfter)
+		}
+	}
+}
+
+func doRateLimitRequest(r http.Handler, path, user, ip string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if user != "" {
+		req.Header.Set("X-User", user)
+	}
+	req.Header.Set("X-Real-IP", ip)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimitMiddlewareKeying(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	l, _ := newTestRateLimiter(t)
+	r := gin.New()
+	r.Use(l.RateLimitMiddleware(1, time.Minute, func(c *gin.Context) string { return c.GetHeader("X-User") }))
+	r.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		name string
+		user string
+		ip   string
+		want int
+	}{
+		{"user first", "bob", "1.1.1.1", http.StatusOK},
+		{"same user other ip", "bob", "2.2.2.2", http.StatusTooManyRequests},
+		{"other user same ip", "alice", "1.1.1.1", http.StatusOK},
+		{"anonymous by ip", "", "1.1.1.1", http.StatusOK},
+		{"anonymous same ip", "", "1.1.1.1", http.StatusTooManyRequests},
+		{"anonymous other ip", "", "3.3.3.3", http.StatusOK},
+	}
+	for _, tt := range tests {
+		w := doRateLimitRequest(r, "/", tt.user, tt.ip)
+		if w.Code != tt.want {
+			t.Fatalf("%s: status %d, want %d", tt.name, w.Code, tt.want)
+		}
+		if w.Code == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+			t.Fatalf("%s: missing Retry-After", tt.name)
+		}
+	}
+}
+
+func TestRateLimitMiddlewareScopedPerLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	l, _ := newTestRateLimiter(t)
+	r := gin.New()
+	r.GET("/strict", l.RateLimitMiddleware(1, time.Minute, nil), func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/loose", l.RateLimitMiddleware(5, time.Minute, nil), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for i := range 3 {
+		if w := doRateLimitRequest(r, "/loose", "", "1.1.1.1"); w.Code != http.StatusOK {
+			t.Fatalf("loose request %d: %d", i, w.Code)
+		}
+	}
+	if w := doRateLimitRequest(r, "/strict", "", "1.1.1.1"); w.Code != http.StatusOK {
+		t.Fatalf("strict window drained by loose group: %d", w.Code)
+	}
+}
+
+func TestRateLimitMiddlewareFailsOpen(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var gotErr error
+	l, mr := newTestRateLimiter(t, WithRateLimitErrorHandler(func(ctx *gin.Context, err error) { gotErr = err }))
+	r := gin.New()
+	r.Use(l.RateLimitMiddleware(1, time.Minute, nil))
+	r.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	mr.Close()
+	if w := doRateLimitRequest(r, "/", "", "1.1.1.1"); w.Code != http.StatusOK {
+		t.Fatalf("status %d, want fail open", w.Code)
+	}
+	if gotErr == nil {
+		t.Fatal("error handler not called")
+	}
+}
@@ -1,6 +1,8 @@
 package niu
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -13,6 +15,14 @@ import (
 	"github.com/gorilla/websocket"
 )
 
+var (
+	ErrHubClosed   = errors.New("hub closed")
+	ErrPongTimeout = errors.New("pong timeout")
+
+	ErrSubprotocolNotSupported = errors.New("subprotocol not supported")
+	ErrCheckAuthNotSet         = errors.New("check auth not set")
+)
+
 // 客户端连接的消息
 type LineMessage struct {
 	UserId   string
@@ -37,8 +47,16 @@ type Line struct {
 	userId     string
 	platform   Platform
 	lastActive int64
+	lastPing   int64 // 服务端最近一次发送 ping 的时间，纳秒
+	lastPong   int64 // 最近一次收到 pong 的时间，纳秒
 	closeChan  chan Empty
 	writeChan  chan []byte
+	closeOnce  sync.Once
+
+	closeSignalOnce sync.Once
+	evicted         atomic.Bool     // 已因空闲被通知关闭，避免重复上报
+	readBufferPool  *ByteBufferPool // 创建连接时 Hub 的读缓冲池，调整缓冲大小不影响已有连接
+	session         *resumeSession  // 未开启断线续传时为 nil
 }
 
 func (ln *Line) Id() string { return ln.id }
@@ -51,79 +69,189 @@ func (ln *Line) LastActive() int64 { return atomic.LoadInt64(&ln.lastActive) }
 
 func (ln *Line) Hub() *Hub { return ln.hub }
 
+// 握手时协商出的子协议，未协商时为空
+func (ln *Line) Subprotocol() string { return ln.conn.Subprotocol() }
+
 func (ln *Line) start() error {
+	// 先同步加入 connections，读写协程触发的注销一定发生在加入之后
+	ln.hub.register(ln)
+
+	ln.hub.lineWg.Add(2)
 	err := ln.hub.pool.Submit(func() {
-		ln.conn.SetPingHandler(func(appData string) error {
-			atomic.StoreInt64(&ln.lastActive, time.Now().Unix())
-			return ln.conn.WriteControl(websocket.PongMessage, []byte(appData), time.Now().Add(ln.hub.writeTimeout))
-		})
-		for {
-			err := ln.conn.SetReadDeadline(time.Now().Add(ln.hub.readTimeout))
-			if err != nil {
-				ln.close(false, err)
-				break
-			}
+		defer ln.hub.lineWg.Done()
+		ln.readLoop()
+	})
+	if err != nil {
+		ln.hub.lineWg.Add(-2)
+		ln.close(false, err)
+		return err
+	}
+
+	err = ln.hub.pool.Submit(func() {
+		defer ln.hub.lineWg.Done()
+		ln.writeLoop()
+	})
+	if err != nil {
+		// 读协程会因连接关闭而退出
+		ln.hub.lineWg.Done()
+		ln.close(false, err)
+		return err
+	}
+	return nil
+}
+
+func (ln *Line) readLoop() {
+	ln.conn.SetPingHandler(func(appData string) error {
+		atomic.StoreInt64(&ln.lastActive, time.Now().Unix())
+		return ln.conn.WriteControl(websocket.PongMessage, []byte(appData), time.Now().Add(ln.hub.writeTimeout))
+	})
+	ln.conn.SetPongHandler(func(string) error {
+		now := time.Now()
+		atomic.StoreInt64(&ln.lastActive, now.Unix())
+		atomic.StoreInt64(&ln.lastPong, now.UnixNano())
+		return nil
+	})
+	for {
+		err := ln.conn.SetReadDeadline(time.Now().Add(ln.hub.readTimeout))
+		if err != nil {
+			ln.close(false, err)
+			return
+		}
+
+		msgType, r, err := ln.conn.NextReader()
+		if err != nil {
+			ln.close(false, err)
+			return
+		}
+		switch msgType {
+		case websocket.CloseMessage:
+			ln.close(false, nil)
+			return
+		case websocket.TextMessage:
+			ln.close(true, nil) // 不允许文本消息
+			return
+		}
+
+		data, err := ln.readMessage(r)
+		if err != nil {
+			ln.close(false, err)
+			return
+		}
+
+		atomic.StoreInt64(&ln.lastActive, time.Now().Unix())
+		// 应用层心跳消息只刷新活跃时间，不转发给业务层
+		if ln.hub.isHeartbeat(data) {
+			continue
+		}
+		ln.hub.messageChan <- &LineMessage{ln.userId, ln.platform, ln.id, data}
+	}
+}
+
+func (ln *Line) readMessage(r io.Reader) ([]byte, error) {
+	// 池化读缓冲，减少读取大消息时的扩容
+	buf := ln.readBufferPool.Get()
+	defer ln.readBufferPool.Put(buf)
+	if _, err := io.Copy(buf, r); err != nil {
+		return nil, err
+	}
 
-			msgType, r, err := ln.conn.NextReader()
-			if err != nil {
+	ln.hub.messageCount.Add(1)
+	ln.hub.messageBytes.Add(int64(buf.Len()))
+	// 缓冲归还后会被其他消息复用，交给业务层的数据需复制一份
+	return bytes.Clone(buf.Bytes()), nil
+}
+
+func (ln *Line) writeLoop() {
+	// 服务端主动 ping，未开启时 pingC 为 nil，永远不会触发
+	var pingC <-chan time.Time
+	if ln.hub.serverPingInterval > 0 {
+		ticker := time.NewTicker(ln.hub.serverPingInterval)
+		defer ticker.Stop()
+		pingC = ticker.C
+	}
+	for {
+		select {
+		case <-pingC:
+			if err := ln.ping(); err != nil {
+				// 连接即将关闭，不再继续 ping，等待 closeChan 退出
+				pingC = nil
 				ln.close(false, err)
-				break
 			}
-			switch msgType {
-			case websocket.CloseMessage:
-				ln.close(false, nil)
-				return
-			case websocket.TextMessage:
-				ln.close(true, nil) // 不允许文本消息
-				return
-			}
-
-			// 池化读缓冲，提高性能
-			buf := ln.hub.readBufferPool.Get()
-			defer ln.hub.readBufferPool.Put(buf)
-			_, err = io.Copy(buf, r)
-			if err != nil {
+		case msg := <-ln.writeChan:
+			if err := ln.write(msg); err != nil {
 				ln.close(false, err)
-				return
 			}
-
-			atomic.StoreInt64(&ln.lastActive, time.Now().Unix())
-			ln.hub.messageChan <- &LineMessage{ln.userId, ln.platform, ln.id, buf.Bytes()}
+		case <-ln.closeChan:
+			ln.flush()
+			ln.close(true, nil)
+			return
 		}
-	})
+	}
+}
+
+func (ln *Line) write(msg []byte) error {
+	err := ln.conn.SetWriteDeadline(time.Now().Add(ln.hub.writeTimeout))
 	if err != nil {
-		ln.conn.Close()
 		return err
 	}
+	return ln.conn.WriteMessage(websocket.BinaryMessage, msg)
+}
 
-	err = ln.hub.pool.Submit(func() {
-		for {
-			select {
-			case msg := <-ln.writeChan:
-				err = ln.conn.SetWriteDeadline(time.Now().Add(ln.hub.writeTimeout))
-				if err != nil {
-					ln.close(false, err)
-				}
-				err = ln.conn.WriteMessage(websocket.BinaryMessage, msg)
-				if err != nil {
-					ln.close(false, err)
-				}
-			case <-ln.closeChan:
-				ln.close(true, nil)
+// 关闭前发送完已排队的消息，写失败（如连接已断开）时放弃剩余的消息
+func (ln *Line) flush() {
+	for {
+		select {
+		case msg := <-ln.writeChan:
+			if err := ln.write(msg); err != nil {
+				ln.close(false, err)
 				return
 			}
+		default:
+			return
 		}
-	})
-	if err != nil {
-		ln.conn.Close()
-		return err
 	}
+}
 
-	ln.hub.registeredChan <- ln
-	return nil
+// 发送消息，开启断线续传时同时记录到会话中
+func (ln *Line) send(data []byte) {
+	if ln.session != nil {
+		ln.session.push(data)
+		return
+	}
+	ln.enqueue(data)
+}
+
+// 将消息放入发送队列，队列满时阻塞，连接关闭后直接丢弃
+func (ln *Line) enqueue(data []byte) {
+	select {
+	case ln.writeChan <- data:
+	case <-ln.closeChan:
+	}
+}
+
+// 通知写协程发送完排队的消息后关闭连接，可重复调用
+func (ln *Line) signalClose() {
+	ln.closeSignalOnce.Do(func() { close(ln.closeChan) })
+}
+
+// 发送 ping 控制帧。上一次 ping 之后仍未收到 pong 时返回 ErrPongTimeout
+func (ln *Line) ping() error {
+	lastPing := atomic.LoadInt64(&ln.lastPing)
+	if lastPing > 0 && atomic.LoadInt64(&ln.lastPong) < lastPing {
+		return ErrPongTimeout
+	}
+
+	now := time.Now()
+	atomic.StoreInt64(&ln.lastPing, now.UnixNano())
+	return ln.conn.WriteControl(websocket.PingMessage, nil, now.Add(ln.hub.writeTimeout))
 }
 
+// 读、写协程都可能触发关闭，只有第一次生效，保证连接只注销一次
 func (ln *Line) close(sendCloseCtrl bool, err error) {
+	ln.closeOnce.Do(func() { ln.doClose(sendCloseCtrl, err) })
+}
+
+func (ln *Line) doClose(sendCloseCtrl bool, err error) {
 	if err != nil {
 		ln.hub.errorChan <- &LineError{ln.userId, ln.platform, ln.id, err}
 	}
@@ -136,13 +264,16 @@ func (ln *Line) close(sendCloseCtrl bool, err error) {
 		ln.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(ln.hub.writeTimeout))
 	}
 	ln.conn.Close()
-	ln.hub.unregisteredChan <- ln
+	// 由读协程发起的关闭也要让写协程退出
+	ln.signalClose()
+	ln.hub.unregister(ln)
 }
 
 // 用户在各个平台的所有连接
 type UserLines struct {
 	sync.RWMutex
-	lines []*Line
+	lines    []*Line
+	sessions []*resumeSession // 已断线、等待续传的会话
 }
 
 // 获取连接数量
@@ -152,12 +283,77 @@ func (u *UserLines) Len() int {
 	return len(u.lines)
 }
 
-// 添加连接
+// 是否既没有连接，也没有等待续传的会话
+func (u *UserLines) isEmpty() bool {
+	u.RLock()
+	defer u.RUnlock()
+	return len(u.lines) == 0 && len(u.sessions) == 0
+}
+
+// 添加连接，同时移除该连接续传的会话，或使用相同 lineId 的旧会话
+// 与 push 在同一把锁下切换，断线会话中的消息不会重复或遗漏
 func (u *UserLines) add(line *Line) {
 	u.Lock()
 	defer u.Unlock()
 
 	u.lines = append(u.lines, line)
+	if len(u.sessions) > 0 {
+		u.sessions = slices.DeleteFunc(u.sessions, func(s *resumeSession) bool {
+			return s == line.session || s.lineId == line.id
+		})
+	}
+}
+
+// 移除已断开的连接，开启断线续传时保留其会话
+func (u *UserLines) remove(line *Line, grace time.Duration) {
+	u.Lock()
+	defer u.Unlock()
+
+	u.lines = slices.DeleteFunc(u.lines, func(v *Line) bool { return v == line })
+	if line.session != nil && !slices.Contains(u.sessions, line.session) {
+		line.session.detach(grace)
+		u.sessions = append(u.sessions, line.session)
+	}
+}
+
+func (u *UserLines) findSession(lineId string, platform Platform) *resumeSession {
+	u.RLock()
+	defer u.RUnlock()
+
+	for _, s := range u.sessions {
+		if s.lineId == lineId && s.platform == platform {
+			return s
+		}
+	}
+	return nil
+}
+
+func (u *UserLines) pruneSessions() {
+	u.Lock()
+	defer u.Unlock()
+
+	if len(u.sessions) == 0 {
+		return
+	}
+	now := time.Now()
+	u.sessions = slices.DeleteFunc(u.sessions, func(s *resumeSession) bool { return s.expired(now) })
+}
+
+// 向匹配的连接以及断线会话发送消息
+func (u *UserLines) push(data []byte, match func(platform Platform, lineId string) bool) {
+	u.RLock()
+	defer u.RUnlock()
+
+	for _, line := range u.lines {
+		if match(line.platform, line.id) {
+			line.send(data)
+		}
+	}
+	for _, s := range u.sessions {
+		if match(s.platform, s.lineId) {
+			s.push(data)
+		}
+	}
 }
 
 // 关闭指定连接
@@ -167,7 +363,7 @@ func (u *UserLines) Close(lineId string) {
 	lines := make([]*Line, 0)
 	for _, v := range u.lines {
 		if v.id == lineId {
-			v.closeChan <- Empty{}
+			v.signalClose()
 		} else {
 			lines = append(lines, v)
 		}
@@ -218,7 +414,7 @@ func (u *UserLines) ClosePlatforms(platforms ...Platform) {
 	lines := make([]*Line, 0)
 	for _, line := range u.lines {
 		if slices.Contains(platforms, line.platform) {
-			line.closeChan <- Empty{}
+			line.signalClose()
 		} else {
 			lines = append(lines, line)
 		}
@@ -240,7 +436,7 @@ func (u *UserLines) ClosePlatformsExcept(exceptPlatforms ...Platform) {
 		if slices.Contains(exceptPlatforms, line.platform) {
 			lines = append(lines, line)
 		} else {
-			line.closeChan <- Empty{}
+			line.signalClose()
 		}
 	}
 	u.lines = lines
@@ -258,7 +454,7 @@ func (u *UserLines) CloseLines(lineIds ...string) {
 	lines := make([]*Line, 0)
 	for _, line := range u.lines {
 		if slices.Contains(lineIds, line.id) {
-			line.closeChan <- Empty{}
+			line.signalClose()
 		} else {
 			lines = append(lines, line)
 		}
@@ -280,30 +476,31 @@ func (u *UserLines) CloseLinesExcept(exceptLineIds ...string) {
 		if slices.Contains(exceptLineIds, line.id) {
 			lines = append(lines, line)
 		} else {
-			line.closeChan <- Empty{}
+			line.signalClose()
 		}
 	}
 	u.lines = lines
 }
 
-// 关闭所有超过指定时间未活跃的连接
-func (u *UserLines) closeInactiveLines(maxIdleSeconds int64) {
+// 关闭所有超过指定时间未活跃的连接，返回被关闭的连接
+func (u *UserLines) closeInactiveLines(maxIdleSeconds int64) []*Line {
 	if maxIdleSeconds <= 0 {
-		return
+		return nil
 	}
 
-	u.Lock()
-	defer u.Unlock()
+	u.RLock()
+	defer u.RUnlock()
 
-	lines := make([]*Line, 0)
+	// 只通知关闭，连接统一由 unregister 移除，避免会话保存前用户已被删除
+	var evicted []*Line
+	now := time.Now().Unix()
 	for _, line := range u.lines {
-		if time.Now().Unix()-line.lastActive > maxIdleSeconds {
-			line.closeChan <- Empty{}
-		} else {
-			lines = append(lines, line)
+		if now-line.LastActive() > maxIdleSeconds && line.evicted.CompareAndSwap(false, true) {
+			line.signalClose()
+			evicted = append(evicted, line)
 		}
 	}
-	u.lines = lines
+	return evicted
 }
 
 // 关闭所有连接
@@ -312,7 +509,7 @@ func (u *UserLines) CloseAll() {
 	defer u.Unlock()
 
 	for _, line := range u.lines {
-		line.closeChan <- Empty{}
+		line.signalClose()
 	}
 	u.lines = make([]*Line, 0)
 }
@@ -323,12 +520,7 @@ func (u *UserLines) PushMessage(data []byte) {
 		return
 	}
 
-	u.RLock()
-	defer u.RUnlock()
-
-	for _, line := range u.lines {
-		line.writeChan <- data
-	}
+	u.push(data, func(Platform, string) bool { return true })
 }
 
 // 向该用户的所有连接发送消息，除了指定平台
@@ -337,15 +529,7 @@ func (u *UserLines) PushMessageExceptPlatforms(data []byte, exceptPlatforms ...P
 		return
 	}
 
-	u.RLock()
-	defer u.RUnlock()
-
-	for _, line := range u.lines {
-		if slices.Contains(exceptPlatforms, line.platform) {
-			continue
-		}
-		line.writeChan <- data
-	}
+	u.push(data, func(platform Platform, _ string) bool { return !slices.Contains(exceptPlatforms, platform) })
 }
 
 // 向该用户的所有连接发送消息，除了指定连接
@@ -354,15 +538,7 @@ func (u *UserLines) PushMessageExceptLines(data []byte, exceptLineIds ...string)
 		return
 	}
 
-	u.RLock()
-	defer u.RUnlock()
-
-	for _, line := range u.lines {
-		if slices.Contains(exceptLineIds, line.id) {
-			continue
-		}
-		line.writeChan <- data
-	}
+	u.push(data, func(_ Platform, lineId string) bool { return !slices.Contains(exceptLineIds, lineId) })
 }
 
 // 向该用户的指定平台发送消息
@@ -371,14 +547,7 @@ func (u *UserLines) PushMessageToPlatforms(data []byte, platforms ...Platform) {
 		return
 	}
 
-	u.RLock()
-	defer u.RUnlock()
-
-	for _, line := range u.lines {
-		if slices.Contains(platforms, line.platform) {
-			line.writeChan <- data
-		}
-	}
+	u.push(data, func(platform Platform, _ string) bool { return slices.Contains(platforms, platform) })
 }
 
 // 向该用户的指定连接发送消息
@@ -387,14 +556,7 @@ func (u *UserLines) PushMessageToLines(data []byte, lineIds ...string) {
 		return
 	}
 
-	u.RLock()
-	defer u.RUnlock()
-
-	for _, line := range u.lines {
-		if slices.Contains(lineIds, line.id) {
-			line.writeChan <- data
-		}
-	}
+	u.push(data, func(_ Platform, lineId string) bool { return slices.Contains(lineIds, lineId) })
 }
 
 type Hub struct {
@@ -402,6 +564,7 @@ type Hub struct {
 
 	subprotocols []string
 	connCount    atomic.Int32 // 所有仍在连接状态的数量
+	closed       atomic.Bool
 	pool         CoroutinePool
 
 	liveCheckDuration  time.Duration
@@ -409,14 +572,83 @@ type Hub struct {
 	connMaxIdleSeconds int64
 	upgrader           websocket.Upgrader
 
-	writeTimeout   time.Duration
-	readTimeout    time.Duration
-	readBufferPool *ByteBufferPool
+	writeTimeout time.Duration
+	readTimeout  time.Duration
+
+	bufferMutex        sync.RWMutex // 保护 upgrader 与读缓冲池，允许运行时调整
+	readBufferPool     *ByteBufferPool
+	readPoolBufferSize int
+	messageCount       atomic.Int64
+	messageBytes       atomic.Int64
+	submitFallbacks    atomic.Int64
 
 	messageChan      chan *LineMessage
 	registeredChan   chan *Line
 	unregisteredChan chan *Line
 	errorChan        chan *LineError
+	evictedChan      chan *Line
+
+	heartbeatEnabled bool
+	heartbeatMsgType byte
+
+	lifecycleMutex sync.RWMutex   // 保证 Close 开始后不会再有新的连接加入 lineWg
+	connMutex      sync.Mutex     // 串行化连接的加入、注销与空用户的删除
+	lineWg         sync.WaitGroup // 所有连接的读写协程
+	done           chan Empty
+	liveCheckDone  chan Empty
+	closeDone      chan Empty // 所有连接退出且通道已关闭
+
+	serverPingInterval time.Duration
+
+	resumeBufferSize int
+	resumeGrace      time.Duration
+
+	checkAuth func(r *http.Request) (userId string, platform Platform, err error)
+}
+
+// Hub 的可选配置
+type HubOption func(*Hub)
+
+// 设置应用层心跳消息的类型，即消息的第一个字节
+// 部分客户端（如某些浏览器库）无法发送 ping 控制帧，只能通过应用层消息保持心跳，
+// 收到该类型的消息时只刷新连接的活跃时间，不会转发到 MessageChan
+// 无论是否设置，EncodePing 生成的心跳包（IsPing）都会被识别为心跳
+func WithHeartbeatMsgType(msgType byte) HubOption {
+	return func(h *Hub) {
+		h.heartbeatEnabled = true
+		h.heartbeatMsgType = msgType
+	}
+}
+
+// 开启服务端主动 ping，每隔 interval 向客户端发送 ping 控制帧
+// 若在下一次 ping 之前仍未收到 pong，则认为连接已失效（如 NAT 超时导致的半开连接）并关闭，
+// 因此失效连接最迟在 2*interval 内被发现，而不必等到 connMaxIdleTime
+func WithServerPingInterval(interval time.Duration) HubOption {
+	return func(h *Hub) {
+		h.serverPingInterval = interval
+	}
+}
+
+// 设置 websocket 连接的读写缓冲区大小，默认均为 4096
+func WithBufferSizes(readBufferSize, writeBufferSize int) HubOption {
+	return func(h *Hub) {
+		h.setBufferSizes(readBufferSize, writeBufferSize, 0)
+	}
+}
+
+// 设置读取消息时池化缓冲的初始容量，默认为 2048，接近常见的消息大小时扩容最少
+func WithReadPoolBufferSize(size int) HubOption {
+	return func(h *Hub) {
+		h.setBufferSizes(0, 0, size)
+	}
+}
+
+// 设置升级连接时的认证函数，供 UpgradeWebSocketWithAuth 使用
+// 可从请求的 header、query 或子协议（Sec-WebSocket-Protocol）中取出 token 并校验
+func WithCheckAuth(fn func(r *http.Request) (userId string, platform Platform, err error)) HubOption {
+	return func(h *Hub) {
+		h.checkAuth = fn
+	}
 }
 
 func NewHub(
@@ -427,6 +659,7 @@ func NewHub(
 	handshakeTimeout time.Duration,
 	enableCompression bool,
 	checkOriginFn func(r *http.Request) bool,
+	opts ...HubOption,
 ) (*Hub, error) {
 	if pool == nil {
 		return nil, errors.New("pool must not nil")
@@ -442,13 +675,18 @@ func NewHub(
 		liveCheckDuration:  liveCheckDuration,
 		readTimeout:        readTimeout,
 		writeTimeout:       writeTimeout,
-		connMaxIdleSeconds: int64(connMaxIdleTime),
+		connMaxIdleSeconds: int64(connMaxIdleTime / time.Second),
 		liveTicker:         time.NewTicker(liveCheckDuration),
-		readBufferPool:     NewByteBufferPool(0, 2048),
+		readBufferPool:     NewByteBufferPool(0, defaultReadPoolBufferSize),
+		readPoolBufferSize: defaultReadPoolBufferSize,
 		messageChan:        make(chan *LineMessage, 4096),
 		registeredChan:     make(chan *Line, 2048),
 		unregisteredChan:   make(chan *Line, 2048),
 		errorChan:          make(chan *LineError, 2048),
+		evictedChan:        make(chan *Line, 2048),
+		done:               make(chan Empty),
+		liveCheckDone:      make(chan Empty),
+		closeDone:          make(chan Empty),
 		upgrader: websocket.Upgrader{
 			EnableCompression: enableCompression,
 			HandshakeTimeout:  handshakeTimeout,
@@ -459,105 +697,211 @@ func NewHub(
 			CheckOrigin:       checkOriginFn,
 		},
 	}
+	for _, opt := range opts {
+		opt(h)
+	}
 
 	// 检测连接可用性
 	err := h.pool.Submit(func() {
-		ticker := h.liveTicker
-		for range ticker.C {
+		defer close(h.liveCheckDone)
+		for {
+			select {
+			case <-h.liveTicker.C:
+			case <-h.done:
+				return
+			}
 			delArr := make([]string, 0)
 			h.connections.Range(func(key, value any) bool {
 				conn := value.(*UserLines)
-				conn.closeInactiveLines(h.connMaxIdleSeconds)
-				if conn.Len() == 0 {
+				for _, ln := range conn.closeInactiveLines(h.connMaxIdleSeconds) {
+					// 业务层未及时读取时丢弃，不阻塞检测
+					select {
+					case h.evictedChan <- ln:
+					default:
+					}
+				}
+				conn.pruneSessions()
+				if conn.isEmpty() {
 					delArr = append(delArr, key.(string))
 				}
 				return true
 			})
 
 			for _, v := range delArr {
-				h.connections.Delete(v)
+				h.deleteIfEmpty(v)
 			}
 		}
 	})
 	if err != nil {
 		return nil, err
 	}
-	// 新的连接加入
-	err = h.pool.Submit(func() {
-		for ln := range h.registeredChan {
-			ln.closeChan = make(chan Empty)
-			ln.writeChan = make(chan []byte, 2048)
-			// 新的连接加入
-			lines, _ := h.connections.LoadOrStore(ln.userId, &UserLines{lines: []*Line{}})
-			lines.(*UserLines).add(ln)
-			h.connCount.Add(1)
-		}
-	})
-	if err != nil {
-		return nil, err
+	return h, nil
+}
+
+const defaultReadPoolBufferSize = 2048
+
+// 运行时调整缓冲区大小，只对之后建立的连接生效，已有连接继续使用原来的缓冲
+// 参数 <=0 时保持原值。可根据 Stats 中的平均消息大小调整
+func (h *Hub) SetBufferSizes(readBufferSize, writeBufferSize, readPoolBufferSize int) {
+	h.bufferMutex.Lock()
+	defer h.bufferMutex.Unlock()
+	h.setBufferSizes(readBufferSize, writeBufferSize, readPoolBufferSize)
+}
+
+func (h *Hub) setBufferSizes(readBufferSize, writeBufferSize, readPoolBufferSize int) {
+	if readBufferSize > 0 {
+		h.upgrader.ReadBufferSize = readBufferSize
 	}
-	// 连接断开
-	err = h.pool.Submit(func() {
-		for ln := range h.unregisteredChan {
-			// 连接断开
-			lines, ok := h.connections.Load(ln.userId)
-			if ok {
-				lines.(*UserLines).Close(ln.id)
-			}
-			h.connCount.Add(-1)
-			// 先删后关，防止在关闭之后，出现向通道意外发送的情况
-			close(ln.closeChan)
-			close(ln.writeChan)
-
-			// 如果用户没有连接，则删除用户
-			if ok && lines.(*UserLines).Len() == 0 {
-				h.connections.Delete(ln.userId)
-			}
+	if writeBufferSize > 0 && writeBufferSize != h.upgrader.WriteBufferSize {
+		h.upgrader.WriteBufferSize = writeBufferSize
+		// 写缓冲池中的缓冲大小必须一致
+		h.upgrader.WriteBufferPool = &sync.Pool{}
+	}
+	if readPoolBufferSize > 0 && readPoolBufferSize != h.readPoolBufferSize {
+		h.readPoolBufferSize = readPoolBufferSize
+		h.readBufferPool = NewByteBufferPool(0, readPoolBufferSize)
+	}
+}
+
+type HubStats struct {
+	LiveCount       int
+	MessageCount    int64   // 收到的消息总数（含应用层心跳）
+	AvgMessageSize  float64 // 收到的消息的平均字节数
+	ReadPoolGets    int64   // 当前读缓冲池（上次调整缓冲区大小以来）获取缓冲的次数
+	ReadPoolHitRate float64 // 当前读缓冲池的命中率，0~1
+	SubmitFallbacks int64   // 推送任务提交到协程池失败、改为同步执行的次数
+}
+
+func (h *Hub) Stats() HubStats {
+	stats := HubStats{
+		LiveCount:       h.LiveCount(),
+		MessageCount:    h.messageCount.Load(),
+		SubmitFallbacks: h.submitFallbacks.Load(),
+	}
+	if stats.MessageCount > 0 {
+		stats.AvgMessageSize = float64(h.messageBytes.Load()) / float64(stats.MessageCount)
+	}
+
+	h.bufferMutex.RLock()
+	gets, misses := h.readBufferPool.Stats()
+	h.bufferMutex.RUnlock()
+	stats.ReadPoolGets = gets
+	if gets > 0 {
+		stats.ReadPoolHitRate = float64(gets-misses) / float64(gets)
+	}
+	return stats
+}
+
+// 连接加入，与注销、删除空用户互斥，避免加入到刚被删除的 UserLines 中
+func (h *Hub) register(ln *Line) {
+	h.connMutex.Lock()
+	lines, _ := h.connections.LoadOrStore(ln.userId, &UserLines{lines: []*Line{}})
+	lines.(*UserLines).add(ln)
+	h.connMutex.Unlock()
+	h.connCount.Add(1)
+
+	// 加入时 Hub 已开始关闭，Close 可能已遍历过 connections，需自行关闭
+	if h.closed.Load() {
+		ln.signalClose()
+	}
+	select {
+	case h.registeredChan <- ln:
+	default:
+	}
+}
+
+// 连接断开，由 doClose 调用，每个连接只会调用一次
+func (h *Hub) unregister(ln *Line) {
+	h.connMutex.Lock()
+	if lines, ok := h.connections.Load(ln.userId); ok {
+		lines.(*UserLines).remove(ln, h.resumeGrace)
+		// 如果用户没有连接，则删除用户
+		if lines.(*UserLines).isEmpty() {
+			h.connections.Delete(ln.userId)
 		}
-	})
-	if err != nil {
-		return nil, err
 	}
+	h.connMutex.Unlock()
+	h.connCount.Add(-1)
 
-	return h, nil
+	select {
+	case h.unregisteredChan <- ln:
+	default:
+	}
+}
+
+// 加锁后再次确认，检测期间可能有新的连接加入
+func (h *Hub) deleteIfEmpty(userId string) {
+	h.connMutex.Lock()
+	defer h.connMutex.Unlock()
+	if lines, ok := h.connections.Load(userId); ok && lines.(*UserLines).isEmpty() {
+		h.connections.Delete(userId)
+	}
+}
+
+// 协议的心跳包始终识别，WithHeartbeatMsgType 设置的类型按第一个字节识别
+func (h *Hub) isHeartbeat(data []byte) bool {
+	if IsPing(data) {
+		return true
+	}
+	return h.heartbeatEnabled && len(data) > 0 && data[0] == h.heartbeatMsgType
 }
 
 // 返回只读通道
 func (h *Hub) MessageChan() <-chan *LineMessage { return h.messageChan }
 
+// 连接加入的通知，仅供业务层监听，不读取也不会影响连接的建立
+// 发送不阻塞：通道（容量 2048）已满时直接丢弃该事件，因此不保证每个连接都会出现，
+// 需要准确的在线状态时应以 GetUserLines、LiveCount 为准
 func (h *Hub) RegisteredChan() <-chan *Line { return h.registeredChan }
 
+// 连接断开的通知，与 RegisteredChan 相同，通道已满时丢弃事件，不阻塞连接的关闭
 func (h *Hub) UnegisteredChan() <-chan *Line { return h.unregisteredChan }
 
 func (h *Hub) ErrorChan() <-chan *LineError { return h.errorChan }
 
+// 因超过 connMaxIdleTime 未活跃而被关闭的连接，用于与客户端主动断开区分
+// 这些连接之后同样会出现在 UnegisteredChan 中。通道满时丢弃事件
+func (h *Hub) EvictedChan() <-chan *Line { return h.evictedChan }
+
 func (h *Hub) LiveCount() int { return int(h.connCount.Load()) }
 
-func (h *Hub) Close(wait time.Duration) {
-	if h.liveTicker != nil {
-		h.liveTicker.Stop()
-		h.liveTicker = nil
-	}
-	h.connections.Range(func(key, value any) bool {
-		value.(*UserLines).CloseAll()
-		return true
-	})
+// 关闭 Hub：通知所有连接发送完已排队的消息后关闭，并等待所有连接的读写协程退出
+// ctx 到期时仍有连接未关闭则返回错误，此时不会关闭 MessageChan 等通道，
+// 以免仍在运行的连接向已关闭的通道发送而 panic。通常是因为没有继续读取 MessageChan 或 ErrorChan
+// 超时后关闭流程仍在后台继续，可再次调用 Close 继续等待，所有连接退出后通道才会被关闭
+func (h *Hub) Close(ctx context.Context) error {
+	h.lifecycleMutex.Lock()
+	first := !h.closed.Swap(true)
+	h.lifecycleMutex.Unlock()
 
-	time.Sleep(wait)
-	defer func() {
-		r := recover()
-		if r != nil {
-			fmt.Println("close chan err")
-		}
-	}()
+	if first {
+		h.liveTicker.Stop()
+		close(h.done)
+		<-h.liveCheckDone
+		h.connections.Range(func(key, value any) bool {
+			value.(*UserLines).CloseAll()
+			return true
+		})
 
-	close(h.messageChan)
-	close(h.registeredChan)
-	close(h.unregisteredChan)
-	close(h.errorChan)
+		go func() {
+			h.lineWg.Wait()
+			// 所有连接的协程都已退出，不会再有发送方
+			close(h.messageChan)
+			close(h.registeredChan)
+			close(h.unregisteredChan)
+			close(h.errorChan)
+			close(h.evictedChan)
+			h.connections.Clear()
+			close(h.closeDone)
+		}()
+	}
 
-	h.readBufferPool = nil
-	h.connections.Clear()
+	select {
+	case <-h.closeDone:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("wait lines close: %d lines still open: %w", h.LiveCount(), ctx.Err())
+	}
 }
 
 // 获取指定用户的所有连接
@@ -587,11 +931,14 @@ func (h *Hub) CloseUserLines(userIds ...string) {
 	}
 }
 
-func (h *Hub) PushMessage(userIds []string, data []byte) {
+func (h *Hub) PushMessage(userIds []string, data []byte) error {
+	if h.closed.Load() {
+		return ErrHubClosed
+	}
 	if len(userIds) == 0 || len(data) == 0 {
-		return
+		return nil
 	}
-	h.pool.Submit(func() {
+	h.submit(func() {
 		for _, userId := range userIds {
 			lines, ok := h.connections.Load(userId)
 			if !ok {
@@ -600,36 +947,172 @@ func (h *Hub) PushMessage(userIds []string, data []byte) {
 			lines.(*UserLines).PushMessage(data)
 		}
 	})
+	return nil
+}
+
+// 按协议编码一次后推送给所有指定用户，requestId 为 0（服务端主动推送）
+// 与 PushMessage 不同，编码失败时返回编码错误
+func (h *Hub) PushMessageProto(userIds []string, protocol *PacketProtocol, msgType, code byte, payload any) error {
+	if h.closed.Load() {
+		return ErrHubClosed
+	}
+	if len(userIds) == 0 {
+		return nil
+	}
+	data, err := protocol.EncodeResp(int32(msgType), 0, code, payload)
+	if err != nil {
+		return err
+	}
+	return h.PushMessage(userIds, data)
 }
 
-func (h *Hub) BroadcastMessage(data []byte) {
+func (h *Hub) BroadcastMessage(data []byte) error {
+	if h.closed.Load() {
+		return ErrHubClosed
+	}
 	if len(data) == 0 {
-		return
+		return nil
 	}
-	h.pool.Submit(func() {
+	h.submit(func() {
 		h.connections.Range(func(key, lns any) bool {
 			lns.(*UserLines).PushMessage(data)
 			return true
 		})
 	})
+	return nil
 }
 
-func (h *Hub) UpgradeWebSocket(userId string, platform Platform, lineId string, w http.ResponseWriter, r *http.Request) error {
-	conn, err := h.upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		return err
+// 向所有用户在指定平台上的连接发送消息
+func (h *Hub) BroadcastToPlatforms(data []byte, platforms ...Platform) error {
+	if h.closed.Load() {
+		return ErrHubClosed
+	}
+	if len(data) == 0 || len(platforms) == 0 {
+		return nil
+	}
+	h.submit(func() {
+		h.connections.Range(func(key, lns any) bool {
+			lns.(*UserLines).PushMessageToPlatforms(data, platforms...)
+			return true
+		})
+	})
+	return nil
+}
+
+// 推送任务提交到协程池失败（如池已满）时在调用方协程中同步执行，保证消息不会被丢弃
+func (h *Hub) submit(task func()) {
+	if err := h.pool.Submit(task); err != nil {
+		h.submitFallbacks.Add(1)
+		task()
 	}
+}
 
-	// 存下该平台新的连接
-	ln := &Line{
+const lineWriteChanSize = 2048
+
+func (h *Hub) newLine(userId string, platform Platform, lineId string) *Line {
+	return &Line{
 		hub:        h,
-		conn:       conn,
 		userId:     userId,
-		platform:   Platform(platform),
+		platform:   platform,
 		id:         lineId,
 		lastActive: time.Now().Unix(),
+		closeChan:  make(chan Empty),
+		writeChan:  make(chan []byte, lineWriteChanSize),
+	}
+}
+
+func (h *Hub) UpgradeWebSocket(userId string, platform Platform, lineId string, w http.ResponseWriter, r *http.Request) error {
+	if h.closed.Load() {
+		return ErrHubClosed
+	}
+
+	// 存下该平台新的连接
+	ln := h.newLine(userId, platform, lineId)
+	if h.resumeBufferSize > 0 {
+		ln.session = newResumeSession(ln, h.resumeBufferSize)
+	}
+	return h.upgrade(ln, w, r)
+}
+
+// 使用 WithCheckAuth 设置的认证函数得到用户与平台后再升级连接
+// 认证失败时在握手前返回 401，不会注册连接；lineId 为空时自动生成
+func (h *Hub) UpgradeWebSocketWithAuth(lineId string, w http.ResponseWriter, r *http.Request) error {
+	if h.checkAuth == nil {
+		return ErrCheckAuthNotSet
+	}
+	userId, platform, err := h.checkAuth(r)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return err
+	}
+	if len(lineId) == 0 {
+		lineId = NewUUIDWithoutDash()
+	}
+	return h.UpgradeWebSocket(userId, platform, lineId, w, r)
+}
+
+// 断线重连并补发 lastSeq 之后的消息，需开启 WithResumeBuffer
+// lineId 需与断线前的连接相同，lastSeq 为客户端在断线前收到的消息数量
+// 会话不存在、已过期或 lastSeq 之后的消息已被淘汰时，不会升级连接并返回 ErrResumeNotAvailable，
+// 此时可改用 UpgradeWebSocket 建立新的连接
+func (h *Hub) UpgradeWebSocketResume(userId string, platform Platform, lineId string, lastSeq int, w http.ResponseWriter, r *http.Request) error {
+	if h.closed.Load() {
+		return ErrHubClosed
+	}
+	if h.resumeBufferSize <= 0 {
+		return ErrResumeNotAvailable
+	}
+	lines := h.GetUserLines(userId)
+	if lines == nil {
+		return ErrResumeNotAvailable
+	}
+	session := lines.findSession(lineId, platform)
+	if session == nil {
+		return ErrResumeNotAvailable
 	}
 
+	ln := h.newLine(userId, platform, lineId)
+	ln.session = session
+	if err := session.attach(ln, lastSeq); err != nil {
+		return err
+	}
+	err := h.upgrade(ln, w, r)
+	if err != nil {
+		// 握手失败、子协议不匹配或握手期间 Hub 已关闭时连接不会启动，会话还给等待续传的状态
+		session.release(ln, h.resumeGrace)
+		// 连接未启动，关闭后不会再有发送方阻塞在它的发送队列上
+		ln.signalClose()
+	}
+	return err
+}
+
+func (h *Hub) upgrade(ln *Line, w http.ResponseWriter, r *http.Request) error {
+	h.bufferMutex.RLock()
+	upgrader := h.upgrader
+	ln.readBufferPool = h.readBufferPool
+	h.bufferMutex.RUnlock()
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return err
+	}
+	// 配置了子协议时，客户端必须协商出其中之一
+	// 客户端未请求或请求的都不支持时 Upgrade 仍会成功，只是协商结果为空
+	if len(h.subprotocols) > 0 && !slices.Contains(h.subprotocols, conn.Subprotocol()) {
+		message := websocket.FormatCloseMessage(websocket.CloseProtocolError, ErrSubprotocolNotSupported.Error())
+		conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(h.writeTimeout))
+		conn.Close()
+		return ErrSubprotocolNotSupported
+	}
+	ln.conn = conn
+
+	// 握手期间 Hub 可能已关闭，检查与启动需在锁内完成，避免 Close 等待不到该连接
+	h.lifecycleMutex.RLock()
+	defer h.lifecycleMutex.RUnlock()
+	if h.closed.Load() {
+		conn.Close()
+		return ErrHubClosed
+	}
 	// 开始监听该连接的消息
 	return ln.start()
 }
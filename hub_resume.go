@@ -0,0 +1,119 @@
+package niu
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrResumeNotAvailable = errors.New("resume session not available")
+
+// 开启断线续传：服务端按顺序为每个连接下发的消息编号（从1开始），并缓存最近的 size 条
+// 客户端记录收到的消息数量即为最后的 seq，断线后在 grace 时间内使用相同的 lineId 调用
+// UpgradeWebSocketResume 重连，即可补发 seq 之后的消息，断线期间推送给该连接的消息同样会被缓存
+// 内存上限为 每个连接（含断线未过期的）size 条消息，size 不能超过单个连接的发送队列长度 2048
+// 推送不会因客户端读取过慢而阻塞：发送队列已满时关闭该连接，之后的消息由客户端续传补发
+func WithResumeBuffer(size int, grace time.Duration) HubOption {
+	return func(h *Hub) {
+		h.resumeBufferSize = min(size, lineWriteChanSize)
+		h.resumeGrace = grace
+	}
+}
+
+// 一个可续传的连接会话，连接断开后保留 grace 时间
+type resumeSession struct {
+	lineId   string
+	platform Platform
+	size     int
+
+	mutex    sync.Mutex
+	msgs     [][]byte
+	firstSeq int // msgs[0] 的 seq
+	nextSeq  int
+	line     *Line // 当前连接，断线期间为 nil
+	overflow bool  // 当前连接的发送队列已满并已通知关闭，之后的消息只缓存不入队
+	expireAt time.Time
+}
+
+func newResumeSession(line *Line, size int) *resumeSession {
+	return &resumeSession{
+		lineId:   line.id,
+		platform: line.platform,
+		size:     size,
+		firstSeq: 1,
+		nextSeq:  1,
+		line:     line,
+	}
+}
+
+// 记录消息并放入当前连接的发送队列，记录与入队在同一把锁内，保证 seq 与实际发送顺序一致
+// 入队不能阻塞：调用方持有 UserLines 的读锁，且续传握手期间连接尚未启动，队列满时不会被消费
+func (s *resumeSession) push(data []byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.msgs = append(s.msgs, data)
+	s.nextSeq++
+	if len(s.msgs) > s.size {
+		s.msgs[0] = nil
+		s.msgs = s.msgs[1:]
+		s.firstSeq++
+	}
+	if s.line == nil || s.overflow {
+		return
+	}
+	select {
+	case s.line.writeChan <- data:
+	default:
+		// 跳过这条消息会使客户端的 seq 计数出错，改为关闭连接，由续传补发之后的消息
+		s.overflow = true
+		s.line.signalClose()
+	}
+}
+
+// 将会话交给重连的连接，并把 lastSeq 之后的消息放入其发送队列
+func (s *resumeSession) attach(line *Line, lastSeq int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.line != nil || time.Now().After(s.expireAt) {
+		return ErrResumeNotAvailable
+	}
+	// lastSeq 之后的消息已被淘汰，或客户端声称收到的比实际发送的多
+	if lastSeq < s.firstSeq-1 || lastSeq >= s.nextSeq {
+		return ErrResumeNotAvailable
+	}
+
+	s.line = line
+	s.overflow = false
+	// 新连接的发送队列为空且容量不小于 size，不会阻塞
+	for _, msg := range s.msgs[lastSeq+1-s.firstSeq:] {
+		line.writeChan <- msg
+	}
+	return nil
+}
+
+func (s *resumeSession) detach(grace time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.line = nil
+	s.expireAt = time.Now().Add(grace)
+}
+
+// 续传的连接未能启动时归还会话；会话已由连接关闭流程释放或已被其他连接接管时不处理
+func (s *resumeSession) release(line *Line, grace time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.line == line {
+		s.line = nil
+		s.expireAt = time.Now().Add(grace)
+	}
+}
+
+func (s *resumeSession) expired(now time.Time) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.line == nil && now.After(s.expireAt)
+}
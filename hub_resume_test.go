@@ -0,0 +1,228 @@
+package niu
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// 查询参数带 seq 时续传，否则建立新连接；续传的错误写入 resumeErr
+func newTestResumeServer(t *testing.T, h *Hub, resumeErr chan<- error) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s := r.URL.Query().Get("seq"); s != "" {
+			seq, _ := strconv.Atoi(s)
+			err := h.UpgradeWebSocketResume("u1", Web, "l1", seq, w, r)
+			if err != nil && errors.Is(err, ErrResumeNotAvailable) {
+				w.WriteHeader(http.StatusConflict)
+			}
+			resumeErr <- err
+			return
+		}
+		h.UpgradeWebSocket("u1", Web, "l1", w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func readTestMessages(t *testing.T, c *websocket.Conn, n int) []byte {
+	t.Helper()
+	got := make([]byte, 0, n)
+	for range n {
+		c.SetReadDeadline(time.Now().Add(time.Second))
+		_, m, err := c.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, m...)
+	}
+	return got
+}
+
+func testResumeSession(h *Hub) *resumeSession {
+	lines := h.GetUserLines("u1")
+	if lines == nil {
+		return nil
+	}
+	return lines.findSession("l1", Web)
+}
+
+// 断线后等待会话进入可续传状态
+func dropTestLine(t *testing.T, h *Hub, c *websocket.Conn) {
+	t.Helper()
+	c.UnderlyingConn().Close()
+	waitFor(t, func() bool {
+		s := testResumeSession(h)
+		if s == nil {
+			return false
+		}
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		return s.line == nil
+	})
+}
+
+func TestHubDropThenResume(t *testing.T) {
+	h := newTestHub(t, WithResumeBuffer(10, time.Minute))
+	resumeErr := make(chan error, 10)
+	url := newTestResumeServer(t, h, resumeErr)
+
+	c := dialTestHub(t, url)
+	waitFor(t, func() bool { return h.LiveCount() == 1 })
+	for i := byte(1); i <= 3; i++ {
+		h.GetUserLines("u1").PushMessage([]byte{i})
+	}
+	// 客户端只收到前两条就断线
+	if got := readTestMessages(t, c, 2); string(got) != "\x01\x02" {
+		t.Fatalf("before drop got %v", got)
+	}
+	dropTestLine(t, h, c)
+
+	// 断线期间推送的消息同样缓存
+	for i := byte(4); i <= 5; i++ {
+		h.GetUserLines("u1").PushMessage([]byte{i})
+	}
+	waitFor(t, func() bool {
+		s := testResumeSession(h)
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		return s.nextSeq == 6
+	})
+
+	if _, _, err := websocket.DefaultDialer.Dial(url+"?seq=99", nil); err == nil {
+		t.Fatal("resume beyond sent seq should fail")
+	}
+	if err := <-resumeErr; !errors.Is(err, ErrResumeNotAvailable) {
+		t.Fatalf("resume beyond sent seq: %v", err)
+	}
+
+	c2 := dialTestHub(t, url+"?seq=2")
+	if err := <-resumeErr; err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestMessages(t, c2, 3); string(got) != "\x03\x04\x05" {
+		t.Fatalf("resumed got %v", got)
+	}
+	h.GetUserLines("u1").PushMessage([]byte{6})
+	if got := readTestMessages(t, c2, 1); string(got) != "\x06" {
+		t.Fatalf("live after resume got %v", got)
+	}
+}
+
+func TestHubResumeFailureReleasesSession(t *testing.T) {
+	h := newTestHub(t, WithResumeBuffer(10, time.Minute))
+	resumeErr := make(chan error, 10)
+	url := newTestResumeServer(t, h, resumeErr)
+
+	c := dialTestHub(t, url)
+	waitFor(t, func() bool { return h.LiveCount() == 1 })
+	h.GetUserLines("u1").PushMessage([]byte{1})
+	readTestMessages(t, c, 1)
+	dropTestLine(t, h, c)
+
+	// 非 websocket 请求，握手失败
+	resp, err := http.Get("http" + strings.TrimPrefix(url, "ws") + "?seq=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if err := <-resumeErr; err == nil {
+		t.Fatal("plain http resume should fail")
+	}
+
+	// 握手完成后、启动前 Hub 被关闭
+	h.bufferMutex.Lock()
+	checkOrigin := h.upgrader.CheckOrigin
+	h.upgrader.CheckOrigin = func(r *http.Request) bool {
+		h.closed.Store(true)
+		return true
+	}
+	h.bufferMutex.Unlock()
+	if c, _, err := websocket.DefaultDialer.Dial(url+"?seq=1", nil); err == nil {
+		c.Close()
+	}
+	if err := <-resumeErr; !errors.Is(err, ErrHubClosed) {
+		t.Fatalf("resume during close: %v, want ErrHubClosed", err)
+	}
+	h.closed.Store(false)
+	h.bufferMutex.Lock()
+	h.upgrader.CheckOrigin = checkOrigin
+	h.bufferMutex.Unlock()
+
+	// 以上失败都应归还会话，仍可续传
+	c2 := dialTestHub(t, url+"?seq=1")
+	if err := <-resumeErr; err != nil {
+		t.Fatalf("resume after failures: %v", err)
+	}
+	h.GetUserLines("u1").PushMessage([]byte{2})
+	if got := readTestMessages(t, c2, 1); string(got) != "\x02" {
+		t.Fatalf("got %v", got)
+	}
+}
+
+// 续传握手期间发送队列已满，推送不能阻塞，握手失败后会话仍可续传
+func TestHubResumeFullQueueDuringFailedUpgrade(t *testing.T) {
+	h := newTestHub(t, WithResumeBuffer(lineWriteChanSize, time.Minute))
+	resumeErr := make(chan error, 10)
+	url := newTestResumeServer(t, h, resumeErr)
+
+	c := dialTestHub(t, url)
+	waitFor(t, func() bool { return h.LiveCount() == 1 })
+	dropTestLine(t, h, c)
+	for i := range lineWriteChanSize {
+		h.GetUserLines("u1").PushMessage([]byte{byte(i)})
+	}
+
+	// attach 已将缓存的消息放满新连接的发送队列，此时再推送一条后拒绝握手
+	var resumed *Line
+	h.bufferMutex.Lock()
+	checkOrigin := h.upgrader.CheckOrigin
+	h.upgrader.CheckOrigin = func(r *http.Request) bool {
+		s := testResumeSession(h)
+		s.mutex.Lock()
+		resumed = s.line
+		s.mutex.Unlock()
+		h.GetUserLines("u1").PushMessage([]byte{0xff})
+		return false
+	}
+	h.bufferMutex.Unlock()
+	if c, _, err := websocket.DefaultDialer.Dial(url+"?seq=0", nil); err == nil {
+		c.Close()
+	}
+	select {
+	case err := <-resumeErr:
+		if err == nil {
+			t.Fatal("resume with rejected origin should fail")
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("resume blocked on a full write queue")
+	}
+	h.bufferMutex.Lock()
+	h.upgrader.CheckOrigin = checkOrigin
+	h.bufferMutex.Unlock()
+
+	if resumed == nil || len(resumed.writeChan) != lineWriteChanSize {
+		t.Fatal("write queue of the resumed line should be full")
+	}
+	select {
+	case <-resumed.closeChan:
+	default:
+		t.Fatal("line of the failed upgrade should be closed")
+	}
+
+	// 最早的一条已被淘汰，从 seq=1 续传可收到其余消息以及握手期间推送的消息
+	c2 := dialTestHub(t, url+"?seq=1")
+	if err := <-resumeErr; err != nil {
+		t.Fatalf("resume after failure: %v", err)
+	}
+	got := readTestMessages(t, c2, lineWriteChanSize)
+	if got[0] != 1 || got[len(got)-1] != 0xff {
+		t.Fatalf("resumed got first %d last %d", got[0], got[len(got)-1])
+	}
+}
@@ -0,0 +1,95 @@
+package niu
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrNoRoute = errors.New("no route for msg type")
+
+// 处理一种类型的消息，req 为按协议解码后的请求
+type RouteHandler func(msg *LineMessage, req *RequestPacket) error
+
+// 按 msgType 分发 Hub 收到的消息，省去每个服务手写解码与 switch
+// Handle、NotFound、OnError 需在 Serve 之前调用，Serve 开始后不可再修改
+type Router struct {
+	protocol *PacketProtocol
+	handlers map[byte]func(msg *LineMessage) error // 负责解码并调用处理函数
+	notFound RouteHandler
+	onError  func(msg *LineMessage, err error)
+}
+
+func NewRouter(protocol *PacketProtocol) *Router {
+	return &Router{
+		protocol: protocol,
+		handlers: make(map[byte]func(msg *LineMessage) error),
+	}
+}
+
+// 注册指定类型消息的处理函数，负载使用 DecodeReq 解码为 any，重复注册会覆盖之前的处理函数
+// Protobuf 协议无法解码为 any，需使用 Handle[T]
+func (r *Router) Handle(msgType byte, handler RouteHandler) {
+	r.handlers[msgType] = func(msg *LineMessage) error {
+		req, err := r.protocol.DecodeReq(msg.Data)
+		if err != nil {
+			return err
+		}
+		return handler(msg, req)
+	}
+}
+
+// 注册指定类型消息的处理函数，负载使用 DecodeReqInto 解码为 T，适用于所有协议
+// 使用 Protobuf 协议时 *T 需实现 proto.Message。重复注册会覆盖之前的处理函数
+func Handle[T any](r *Router, msgType byte, handler func(msg *LineMessage, meta *PacketMetaData, payload *T) error) {
+	r.handlers[msgType] = func(msg *LineMessage) error {
+		payload := new(T)
+		meta, err := r.protocol.DecodeReqInto(msg.Data, payload)
+		if err != nil {
+			return err
+		}
+		return handler(msg, meta, payload)
+	}
+}
+
+// 没有匹配的处理函数时调用，未设置时返回 ErrNoRoute
+// 此时不知道负载的类型，req 只包含元数据，Payload 为 nil，且未经过验签
+func (r *Router) NotFound(handler RouteHandler) {
+	r.notFound = handler
+}
+
+// 解码失败、没有路由或处理函数返回错误时调用，未设置时忽略错误
+func (r *Router) OnError(fn func(msg *LineMessage, err error)) {
+	r.onError = fn
+}
+
+// 解码并分发一条消息，心跳包直接忽略
+func (r *Router) Dispatch(msg *LineMessage) error {
+	if IsPing(msg.Data) {
+		return nil
+	}
+
+	// 先只读取元数据选择处理函数，负载由处理函数按各自的类型解码
+	meta, err := r.protocol.GetMeta(msg.Data)
+	if err != nil {
+		return err
+	}
+
+	handler, ok := r.handlers[meta.MsgType]
+	if !ok {
+		if r.notFound == nil {
+			return fmt.Errorf("%w: %d", ErrNoRoute, meta.MsgType)
+		}
+		return r.notFound(msg, &RequestPacket{PacketMetaData: *meta})
+	}
+	return handler(msg)
+}
+
+// 持续从 hub.MessageChan() 读取并分发消息，直到 Hub 关闭
+// 消息按顺序在当前协程中处理，需要并发处理时可在多个协程中调用 Serve
+func (r *Router) Serve(hub *Hub) {
+	for msg := range hub.MessageChan() {
+		if err := r.Dispatch(msg); err != nil && r.onError != nil {
+			r.onError(msg, err)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package niu
+
+import (
+	"errors"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type routerTestPayload struct {
+	Name string `json:"name" msgpack:"name"`
+}
+
+func TestRouterDispatch(t *testing.T) {
+	signer := NewHmacSigner([]byte("secret"))
+	tests := []struct {
+		name     string
+		protocol *PacketProtocol
+	}{
+		{"json", NewJsonProtocol(signer, nil)},
+		{"msgpack", NewMsgPackProtocol(signer, nil)},
+		{"auto", NewAutoProtocol(signer, nil)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRouter(tt.protocol)
+			var got *routerTestPayload
+			Handle(r, 3, func(msg *LineMessage, meta *PacketMetaData, payload *routerTestPayload) error {
+				if meta.RequestId != 11 || msg.UserId != "u1" {
+					t.Errorf("meta = %+v, msg = %+v", meta, msg)
+				}
+				got = payload
+				return nil
+			})
+
+			data, err := tt.protocol.EncodeReq(3, 11, routerTestPayload{"alice"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := r.Dispatch(&LineMessage{UserId: "u1", Data: data}); err != nil {
+				t.Fatal(err)
+			}
+			if got == nil || got.Name != "alice" {
+				t.Fatalf("payload = %+v", got)
+			}
+		})
+	}
+}
+
+func TestRouterDispatchProtobuf(t *testing.T) {
+	p := NewProtobufProtocol(nil, nil)
+	r := NewRouter(p)
+	var got string
+	Handle(r, 1, func(msg *LineMessage, meta *PacketMetaData, payload *wrapperspb.StringValue) error {
+		got = payload.GetValue()
+		return nil
+	})
+
+	data, err := p.EncodeReq(1, 1, wrapperspb.String("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Dispatch(&LineMessage{Data: data}); err != nil {
+		t.Fatal(err)
+	}
+	if got != "hello" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestRouterUntypedHandle(t *testing.T) {
+	p := NewJsonProtocol(nil, nil)
+	r := NewRouter(p)
+	var got any
+	r.Handle(2, func(msg *LineMessage, req *RequestPacket) error {
+		got = req.Payload
+		return nil
+	})
+	data, _ := p.EncodeReq(2, 1, "x")
+	if err := r.Dispatch(&LineMessage{Data: data}); err != nil || got != "x" {
+		t.Fatalf("got %v, err %v", got, err)
+	}
+}
+
+func TestRouterUnknownType(t *testing.T) {
+	p := NewJsonProtocol(nil, nil)
+	data, _ := p.EncodeReq(9, 1, "x")
+
+	r := NewRouter(p)
+	if err := r.Dispatch(&LineMessage{Data: data}); !errors.Is(err, ErrNoRoute) {
+		t.Fatalf("want ErrNoRoute, got %v", err)
+	}
+
+	var notFound *RequestPacket
+	r.NotFound(func(msg *LineMessage, req *RequestPacket) error {
+		notFound = req
+		return nil
+	})
+	if err := r.Dispatch(&LineMessage{Data: data}); err != nil {
+		t.Fatal(err)
+	}
+	if notFound == nil || notFound.MsgType != 9 || notFound.Payload != nil {
+		t.Fatalf("notFound req = %+v", notFound)
+	}
+
+	if err := r.Dispatch(&LineMessage{Data: EncodePing()}); err != nil {
+		t.Fatalf("ping should be ignored, got %v", err)
+	}
+}
+
+func TestRouterHandlerError(t *testing.T) {
+	p := NewJsonProtocol(nil, nil)
+	r := NewRouter(p)
+	want := errors.New("boom")
+	Handle(r, 1, func(*LineMessage, *PacketMetaData, *routerTestPayload) error { return want })
+	data, _ := p.EncodeReq(1, 1, routerTestPayload{})
+	if err := r.Dispatch(&LineMessage{Data: data}); !errors.Is(err, want) {
+		t.Fatalf("got %v", err)
+	}
+	if err := r.Dispatch(&LineMessage{Data: []byte{1}}); err == nil {
+		t.Fatal("want decode error")
+	}
+}
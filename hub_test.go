@@ -0,0 +1,693 @@
+package niu
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func newTestHub(t *testing.T, opts ...HubOption) *Hub {
+	t.Helper()
+	h := newRawTestHub(t, nil, opts...)
+	go func() {
+		for range h.ErrorChan() {
+		}
+	}()
+	return h
+}
+
+// 同 newTestHub，但不读取 ErrorChan，由调用方读取
+func newRawTestHub(t *testing.T, subprotocols []string, opts ...HubOption) *Hub {
+	t.Helper()
+	pool, err := NewDefaultPool(1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Release)
+	h, err := NewHub(subprotocols, time.Second, time.Hour, time.Minute, time.Second, pool, time.Second, false,
+		func(*http.Request) bool { return true }, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+// 启动 websocket 服务，查询参数 u 为用户 id，id 为连接 id
+func newTestHubServer(t *testing.T, h *Hub) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		h.UpgradeWebSocket(q.Get("u"), Web, q.Get("id"), w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func dialTestHub(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+	c, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestHubCloseRetryAfterTimeout(t *testing.T) {
+	h := newTestHub(t)
+	url := newTestHubServer(t, h)
+	c := dialTestHub(t, url+"?u=u1&id=l1")
+	waitFor(t, func() bool { return h.LiveCount() == 1 })
+
+	// 不读取 MessageChan，读协程阻塞在发送上，Close 无法在超时前完成
+	for range cap(h.messageChan) + 1 {
+		if err := c.WriteMessage(websocket.BinaryMessage, []byte{1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitFor(t, func() bool { return len(h.messageChan) == cap(h.messageChan) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := h.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("first Close: %v, want deadline exceeded", err)
+	}
+	ctx2, cancel2 := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel2()
+	if err := h.Close(ctx2); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("second Close while still blocked: %v, want deadline exceeded", err)
+	}
+
+	drained := make(chan Empty)
+	go func() {
+		for range h.MessageChan() {
+		}
+		close(drained)
+	}()
+	ctx3, cancel3 := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel3()
+	if err := h.Close(ctx3); err != nil {
+		t.Fatalf("retry Close: %v", err)
+	}
+	select {
+	case <-drained:
+	case <-time.After(time.Second):
+		t.Fatal("MessageChan not closed after Close returned nil")
+	}
+	if err := h.Close(context.Background()); err != nil {
+		t.Fatalf("Close after done: %v", err)
+	}
+}
+
+// 并发建立、断开连接并推送消息，业务层不读取 RegisteredChan 与 UnegisteredChan，
+// 通道写满后事件被丢弃，连接的加入与注销不能因此阻塞
+func TestHubRegisterChurn(t *testing.T) {
+	h := newTestHub(t)
+	// 在有连接之前缩小通道，使事件很快写满
+	h.registeredChan = make(chan *Line, 8)
+	h.unregisteredChan = make(chan *Line, 8)
+	go func() {
+		for range h.MessageChan() {
+		}
+	}()
+	url := newTestHubServer(t, h)
+
+	const workers, rounds = 16, 20
+	errs := make(chan error, workers)
+	for w := range workers {
+		go func() {
+			userId := "u" + strconv.Itoa(w%4)
+			for i := range rounds {
+				c, _, err := websocket.DefaultDialer.Dial(url+"?u="+userId+"&id="+strconv.Itoa(w)+"-"+strconv.Itoa(i), nil)
+				if err != nil {
+					errs <- err
+					return
+				}
+				c.WriteMessage(websocket.BinaryMessage, []byte{1})
+				h.PushMessage([]string{userId}, []byte{2})
+				if i%2 == 0 {
+					h.CloseUserLines(userId)
+				}
+				c.Close()
+			}
+			errs <- nil
+		}()
+	}
+	for range workers {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	waitFor(t, func() bool { return h.LiveCount() == 0 })
+	waitFor(t, func() bool {
+		n := 0
+		h.connections.Range(func(any, any) bool { n++; return true })
+		return n == 0
+	})
+	if len(h.registeredChan) != cap(h.registeredChan) || len(h.unregisteredChan) != cap(h.unregisteredChan) {
+		t.Fatalf("event channels should be full: %d %d", len(h.registeredChan), len(h.unregisteredChan))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := h.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 并发建立、断开连接的同时，另一部分连接保持静默，由检测协程因空闲关闭，
+// 空闲关闭与注销、加入交错进行，结束后不能残留连接或空用户
+func TestHubRegisterEvictChurn(t *testing.T) {
+	pool, err := NewDefaultPool(4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Release)
+	h, err := NewHub(nil, time.Second, time.Second, time.Minute, time.Second, pool, time.Second, false,
+		func(*http.Request) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	// NewHub 限制检测间隔不小于 1 秒，这里缩短以增加空闲关闭与注销交错的机会
+	h.liveTicker.Reset(10 * time.Millisecond)
+	go func() {
+		for range h.ErrorChan() {
+		}
+	}()
+	go func() {
+		for range h.MessageChan() {
+		}
+	}()
+	var evicted atomic.Int32
+	go func() {
+		for range h.EvictedChan() {
+			evicted.Add(1)
+		}
+	}()
+	t.Cleanup(func() { h.Close(context.Background()) })
+	url := newTestHubServer(t, h)
+
+	const workers = 8
+	deadline := time.Now().Add(2500 * time.Millisecond)
+	var (
+		mutex  sync.Mutex
+		silent []*websocket.Conn
+	)
+	errs := make(chan error, workers)
+	for w := range workers {
+		go func() {
+			userId := "u" + strconv.Itoa(w%4)
+			for i := 0; time.Now().Before(deadline); i++ {
+				lineId := strconv.Itoa(w) + "-" + strconv.Itoa(i)
+				if i%5 == 0 {
+					// 静默连接，只能由空闲检测关闭
+					c, _, err := websocket.DefaultDialer.Dial(url+"?u="+userId+"&id=s"+lineId, nil)
+					if err != nil {
+						errs <- err
+						return
+					}
+					mutex.Lock()
+					silent = append(silent, c)
+					mutex.Unlock()
+				}
+				c, _, err := websocket.DefaultDialer.Dial(url+"?u="+userId+"&id="+lineId, nil)
+				if err != nil {
+					errs <- err
+					return
+				}
+				c.WriteMessage(websocket.BinaryMessage, []byte{1})
+				h.PushMessage([]string{userId}, []byte{2})
+				c.Close()
+				time.Sleep(time.Millisecond)
+			}
+			errs <- nil
+		}()
+	}
+	for range workers {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() {
+		for _, c := range silent {
+			c.Close()
+		}
+	})
+
+	waitFor(t, func() bool { return h.LiveCount() == 0 })
+	waitFor(t, func() bool {
+		n := 0
+		h.connections.Range(func(any, any) bool { n++; return true })
+		return n == 0
+	})
+	// 静默连接只会因空闲被关闭
+	waitFor(t, func() bool { return int(evicted.Load()) == len(silent) })
+}
+
+func TestHubSendAfterClose(t *testing.T) {
+	h := newTestHub(t)
+	if err := h.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	protocol := NewJsonProtocol(nil, nil)
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"PushMessage", func() error { return h.PushMessage([]string{"u1"}, []byte{1}) }},
+		{"PushMessageProto", func() error { return h.PushMessageProto([]string{"u1"}, protocol, 1, 0, "x") }},
+		{"BroadcastMessage", func() error { return h.BroadcastMessage([]byte{1}) }},
+		{"BroadcastToPlatforms", func() error { return h.BroadcastToPlatforms([]byte{1}, Web) }},
+		{"UpgradeWebSocket", func() error {
+			return h.UpgradeWebSocket("u1", Web, "l1", httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		}},
+	}
+	for _, tt := range tests {
+		if err := tt.call(); !errors.Is(err, ErrHubClosed) {
+			t.Errorf("%s: %v, want ErrHubClosed", tt.name, err)
+		}
+	}
+}
+
+// 超过 connMaxIdleTime 未发送任何消息的连接被关闭并出现在 EvictedChan 中，持续活跃的连接不受影响
+func TestHubEvictIdleLine(t *testing.T) {
+	pool, err := NewDefaultPool(1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Release)
+	h, err := NewHub(nil, time.Second, time.Second, time.Minute, time.Second, pool, time.Second, false,
+		func(*http.Request) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for range h.ErrorChan() {
+		}
+	}()
+	go func() {
+		for range h.MessageChan() {
+		}
+	}()
+	t.Cleanup(func() { h.Close(context.Background()) })
+	url := newTestHubServer(t, h)
+
+	dialTestHub(t, url+"?u=u1&id=silent")
+	active := dialTestHub(t, url+"?u=u1&id=active")
+	waitFor(t, func() bool { return h.LiveCount() == 2 })
+
+	stop := make(chan Empty)
+	defer close(stop)
+	go func() {
+		ticker := time.NewTicker(200 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				active.WriteMessage(websocket.BinaryMessage, []byte{1})
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	select {
+	case ln := <-h.EvictedChan():
+		if ln.Id() != "silent" {
+			t.Fatalf("evicted %s, want silent", ln.Id())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("idle line not evicted")
+	}
+	waitFor(t, func() bool { return h.LiveCount() == 1 })
+	if h.GetUserLines("u1").Get("active") == nil {
+		t.Fatal("active line should not be evicted")
+	}
+}
+
+func TestHubBroadcastToPlatforms(t *testing.T) {
+	h := newTestHub(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		p, _ := strconv.Atoi(q.Get("p"))
+		h.UpgradeWebSocket(q.Get("u"), Platform(p), q.Get("id"), w, r)
+	}))
+	t.Cleanup(srv.Close)
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	dial := func(userId string, platform Platform) *websocket.Conn {
+		return dialTestHub(t, url+"?u="+userId+"&id="+platform.String()+"&p="+strconv.Itoa(int(platform)))
+	}
+	web, iphone, mac := dial("u1", Web), dial("u1", IPhone), dial("u1", Mac)
+	android := dial("u2", Android)
+	waitFor(t, func() bool { return h.LiveCount() == 4 })
+
+	if err := h.BroadcastToPlatforms([]byte{1}, IPhone, Android); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []*websocket.Conn{iphone, android} {
+		if got := readTestMessages(t, c, 1); string(got) != "\x01" {
+			t.Fatalf("selected platform got %v", got)
+		}
+	}
+
+	// 选中的连接收到时广播已遍历完所有用户，未选中的连接若也收到，会排在随后推送的消息之前
+	h.GetUserLines("u1").PushMessageToPlatforms([]byte{2}, Web, Mac)
+	for _, c := range []*websocket.Conn{web, mac} {
+		if got := readTestMessages(t, c, 1); string(got) != "\x02" {
+			t.Fatalf("unselected platform got %v", got)
+		}
+	}
+}
+
+// 服务端主动 ping，不回复 pong 的连接在下一次 ping 时被关闭，正常回复的连接不受影响
+func TestHubPongTimeout(t *testing.T) {
+	h := newRawTestHub(t, nil, WithServerPingInterval(50*time.Millisecond))
+	t.Cleanup(func() { h.Close(context.Background()) })
+	url := newTestHubServer(t, h)
+
+	silent := dialTestHub(t, url+"?u=u1&id=silent")
+	silent.SetPingHandler(func(string) error { return nil })
+	silentClosed := make(chan Empty)
+	go func() {
+		defer close(silentClosed)
+		for {
+			if _, _, err := silent.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	answering := dialTestHub(t, url+"?u=u1&id=answering")
+	go func() {
+		for {
+			// 默认的 ping 处理函数会回复 pong
+			if _, _, err := answering.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	waitFor(t, func() bool { return h.LiveCount() == 2 })
+
+	select {
+	case e := <-h.ErrorChan():
+		if e.LineId != "silent" || !errors.Is(e.Error, ErrPongTimeout) {
+			t.Fatalf("got error %v on line %s, want ErrPongTimeout on silent", e.Error, e.LineId)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("pong timeout not reported")
+	}
+	select {
+	case <-silentClosed:
+	case <-time.After(time.Second):
+		t.Fatal("silent client not disconnected")
+	}
+	waitFor(t, func() bool { return h.LiveCount() == 1 })
+
+	// 再经过多个 ping 周期，回复 pong 的连接仍然在线
+	time.Sleep(300 * time.Millisecond)
+	if h.LiveCount() != 1 || h.GetUserLines("u1").Get("answering") == nil {
+		t.Fatal("answering line should stay connected")
+	}
+	select {
+	case e := <-h.ErrorChan():
+		t.Fatalf("unexpected error %v on line %s", e.Error, e.LineId)
+	default:
+	}
+}
+
+// 配置了子协议时，未协商出其中之一的连接在注册前被关闭
+func TestHubRejectsUnnegotiatedSubprotocol(t *testing.T) {
+	h := newRawTestHub(t, []string{"v1"})
+	t.Cleanup(func() { h.Close(context.Background()) })
+	upgradeErr := make(chan error, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		upgradeErr <- h.UpgradeWebSocket(q.Get("u"), Web, q.Get("id"), w, r)
+	}))
+	t.Cleanup(srv.Close)
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	for _, protocols := range [][]string{nil, {"v2"}} {
+		dialer := websocket.Dialer{Subprotocols: protocols}
+		c, _, err := dialer.Dial(url+"?u=u1&id=l1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.SetReadDeadline(time.Now().Add(time.Second))
+		_, _, err = c.ReadMessage()
+		c.Close()
+		if !websocket.IsCloseError(err, websocket.CloseProtocolError) {
+			t.Fatalf("subprotocols %v: read error %v, want CloseProtocolError", protocols, err)
+		}
+		if err := <-upgradeErr; !errors.Is(err, ErrSubprotocolNotSupported) {
+			t.Fatalf("subprotocols %v: upgrade error %v, want ErrSubprotocolNotSupported", protocols, err)
+		}
+		if h.LiveCount() != 0 || h.GetUserLines("u1") != nil || len(h.RegisteredChan()) != 0 {
+			t.Fatalf("subprotocols %v: line should not be registered", protocols)
+		}
+	}
+
+	dialer := websocket.Dialer{Subprotocols: []string{"v1"}}
+	c, _, err := dialer.Dial(url+"?u=u1&id=l1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	if err := <-upgradeErr; err != nil {
+		t.Fatal(err)
+	}
+	ln := h.GetUserLines("u1").Get("l1")
+	if ln == nil || ln.Subprotocol() != "v1" {
+		t.Fatalf("line = %v, want subprotocol v1", ln)
+	}
+}
+
+func TestHubUpgradeWithAuth(t *testing.T) {
+	errUnauthorized := errors.New("bad token")
+	h := newTestHub(t, WithCheckAuth(func(r *http.Request) (string, Platform, error) {
+		if r.Header.Get("Authorization") != "token-u1" {
+			return "", Unspecify, errUnauthorized
+		}
+		return "u1", IPhone, nil
+	}))
+	t.Cleanup(func() { h.Close(context.Background()) })
+	upgradeErr := make(chan error, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgradeErr <- h.UpgradeWebSocketWithAuth("", w, r)
+	}))
+	t.Cleanup(srv.Close)
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	_, resp, err := websocket.DefaultDialer.Dial(url, http.Header{"Authorization": {"wrong"}})
+	if !errors.Is(err, websocket.ErrBadHandshake) || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("dial with bad token: %v, want 401", err)
+	}
+	if err := <-upgradeErr; !errors.Is(err, errUnauthorized) {
+		t.Fatalf("upgrade error %v, want the checkAuth error", err)
+	}
+	if h.LiveCount() != 0 {
+		t.Fatalf("live count = %d after failed auth", h.LiveCount())
+	}
+
+	c, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Authorization": {"token-u1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	if err := <-upgradeErr; err != nil {
+		t.Fatal(err)
+	}
+	lines := h.GetUserLines("u1")
+	if lines == nil || lines.Len() != 1 {
+		t.Fatal("authenticated line not registered under u1")
+	}
+	ln := lines.GetPlatformLines(IPhone)
+	if len(ln) != 1 || ln[0].Id() == "" {
+		t.Fatalf("lines on IPhone = %v, want one line with a generated id", ln)
+	}
+}
+
+func TestHubUpgradeWithAuthNotSet(t *testing.T) {
+	h := newTestHub(t)
+	w := httptest.NewRecorder()
+	err := h.UpgradeWebSocketWithAuth("l1", w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !errors.Is(err, ErrCheckAuthNotSet) {
+		t.Fatalf("err = %v, want ErrCheckAuthNotSet", err)
+	}
+	if h.LiveCount() != 0 {
+		t.Fatalf("live count = %d", h.LiveCount())
+	}
+}
+
+// 心跳消息只刷新活跃时间，不转发给业务层
+func TestHubHeartbeat(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      []HubOption
+		heartbeat []byte
+	}{
+		{"custom msg type", []HubOption{WithHeartbeatMsgType(0x01)}, []byte{0x01, 0xAA}},
+		{"protocol ping", nil, EncodePing()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHub(t, tt.opts...)
+			t.Cleanup(func() { h.Close(context.Background()) })
+			url := newTestHubServer(t, h)
+			c := dialTestHub(t, url+"?u=u1&id=l1")
+			waitFor(t, func() bool { return h.LiveCount() == 1 })
+			ln := h.GetUserLines("u1").Get("l1")
+			atomic.StoreInt64(&ln.lastActive, 0)
+
+			if err := c.WriteMessage(websocket.BinaryMessage, tt.heartbeat); err != nil {
+				t.Fatal(err)
+			}
+			waitFor(t, func() bool { return ln.LastActive() > 0 })
+			if err := c.WriteMessage(websocket.BinaryMessage, []byte{0x02, 0xBB}); err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case msg := <-h.MessageChan():
+				if string(msg.Data) != "\x02\xBB" {
+					t.Fatalf("got %x, want only the normal frame", msg.Data)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("normal frame not forwarded")
+			}
+			if n := len(h.MessageChan()); n != 0 {
+				t.Fatalf("%d extra messages forwarded", n)
+			}
+		})
+	}
+}
+
+func TestHubPushMessageProto(t *testing.T) {
+	type notice struct {
+		Title string `json:"title"`
+		Count int    `json:"count"`
+	}
+	h := newTestHub(t)
+	t.Cleanup(func() { h.Close(context.Background()) })
+	url := newTestHubServer(t, h)
+	c := dialTestHub(t, url+"?u=u1&id=l1")
+	waitFor(t, func() bool { return h.LiveCount() == 1 })
+	protocol := NewJsonProtocol(nil, nil)
+
+	if err := h.PushMessageProto([]string{"u1"}, protocol, 7, 3, notice{"hi", 2}); err != nil {
+		t.Fatal(err)
+	}
+	c.SetReadDeadline(time.Now().Add(time.Second))
+	_, data, err := c.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got notice
+	resp, err := protocol.DecodeRespInto(data, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.MsgType != 7 || resp.Code != 3 || resp.RequestId != 0 || got != (notice{"hi", 2}) {
+		t.Fatalf("got msgType %d code %d requestId %d payload %+v", resp.MsgType, resp.Code, resp.RequestId, got)
+	}
+}
+
+// 编码失败时返回编码错误，不推送任何数据
+func TestHubPushMessageProtoMarshalError(t *testing.T) {
+	h := newTestHub(t)
+	t.Cleanup(func() { h.Close(context.Background()) })
+	url := newTestHubServer(t, h)
+	c := dialTestHub(t, url+"?u=u1&id=l1")
+	waitFor(t, func() bool { return h.LiveCount() == 1 })
+
+	err := h.PushMessageProto([]string{"u1"}, NewJsonProtocol(nil, nil), 7, 0, make(chan int))
+	var typeErr *json.UnsupportedTypeError
+	if !errors.As(err, &typeErr) {
+		t.Fatalf("err = %v, want the marshal error", err)
+	}
+	// 若编码失败时仍有数据推送，会排在随后推送的消息之前
+	h.PushMessage([]string{"u1"}, []byte{1})
+	if got := readTestMessages(t, c, 1); string(got) != "\x01" {
+		t.Fatalf("got %v, want only the later message", got)
+	}
+}
+
+func TestHubStats(t *testing.T) {
+	h := newTestHub(t)
+	t.Cleanup(func() { h.Close(context.Background()) })
+	url := newTestHubServer(t, h)
+	c := dialTestHub(t, url+"?u=u1&id=l1")
+	waitFor(t, func() bool { return h.LiveCount() == 1 })
+
+	for _, size := range []int{10, 20, 60} {
+		if err := c.WriteMessage(websocket.BinaryMessage, make([]byte, size)); err != nil {
+			t.Fatal(err)
+		}
+		<-h.MessageChan()
+	}
+	stats := h.Stats()
+	if stats.LiveCount != 1 || stats.MessageCount != 3 || stats.AvgMessageSize != 30 || stats.ReadPoolGets != 3 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	// sync.Pool 不保证复用，只校验范围
+	if stats.ReadPoolHitRate < 0 || stats.ReadPoolHitRate > 1 {
+		t.Fatalf("hit rate = %v", stats.ReadPoolHitRate)
+	}
+}
+
+// 调整缓冲区大小只影响之后建立的连接，已有连接继续使用原来的读缓冲池
+func TestHubSetBufferSizesAppliesToNewLines(t *testing.T) {
+	h := newTestHub(t)
+	t.Cleanup(func() { h.Close(context.Background()) })
+	url := newTestHubServer(t, h)
+	oldConn := dialTestHub(t, url+"?u=u1&id=old")
+	waitFor(t, func() bool { return h.LiveCount() == 1 })
+	oldPool := h.readBufferPool
+
+	h.SetBufferSizes(8192, 8192, 4096)
+	if h.readBufferPool == oldPool || h.upgrader.ReadBufferSize != 8192 || h.upgrader.WriteBufferSize != 8192 {
+		t.Fatal("buffer sizes not updated")
+	}
+	dialTestHub(t, url+"?u=u1&id=new")
+	waitFor(t, func() bool { return h.LiveCount() == 2 })
+
+	lines := h.GetUserLines("u1")
+	if lines.Get("old").readBufferPool != oldPool {
+		t.Fatal("existing line switched to the new read buffer pool")
+	}
+	if lines.Get("new").readBufferPool != h.readBufferPool {
+		t.Fatal("new line does not use the new read buffer pool")
+	}
+
+	// 已有连接的读取计入原来的缓冲池，Stats 只统计当前缓冲池
+	if err := oldConn.WriteMessage(websocket.BinaryMessage, []byte{1}); err != nil {
+		t.Fatal(err)
+	}
+	<-h.MessageChan()
+	if gets, _ := oldPool.Stats(); gets != 1 {
+		t.Fatalf("old pool gets = %d, want 1", gets)
+	}
+	if stats := h.Stats(); stats.ReadPoolGets != 0 || stats.MessageCount != 1 {
+		t.Fatalf("stats = %+v", stats)
+	}
+}
@@ -2,10 +2,16 @@ package niu
 
 import (
 	"encoding/json"
+	"errors"
 
 	"github.com/shamaton/msgpack/v2"
+	"google.golang.org/protobuf/proto"
 )
 
+var ErrNotProtoMessage = errors.New("value is not a proto.Message")
+
+// 负载的序列化方式。通信双方需使用相同的实现，协议中不会携带序列化方式
+// （NewAutoProtocol 的 MessagePack/JSON 标记除外）
 type PayloadMarshaler interface {
 	Marshal(v any) ([]byte, error)
 	Unmarshal(data []byte, v any) error
@@ -32,3 +38,24 @@ func (m *JsonMarshaler) Marshal(v any) ([]byte, error) {
 func (m *JsonMarshaler) Unmarshal(data []byte, v any) error {
 	return json.Unmarshal(data, v)
 }
+
+// Protobuf 序列化，Marshal 与 Unmarshal 的参数必须实现 proto.Message
+// 无法反序列化到 any，解码时需使用 DecodeReqInto / DecodeRespInto 传入具体的消息
+type ProtobufMarshaler struct{}
+
+var protobufMarshaler = &ProtobufMarshaler{}
+
+func (m *ProtobufMarshaler) Marshal(v any) ([]byte, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return nil, ErrNotProtoMessage
+	}
+	return proto.Marshal(msg)
+}
+func (m *ProtobufMarshaler) Unmarshal(data []byte, v any) error {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return ErrNotProtoMessage
+	}
+	return proto.Unmarshal(data, msg)
+}
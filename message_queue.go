@@ -2,15 +2,31 @@ package niu
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
+const (
+	// 拉取消息出错后，等待一段时间再重试，避免 Redis 不可用时空转
+	consumeErrorBackoff = time.Second
+	// 拉取新消息时的最长阻塞时间。go-redis 不会因 ctx 取消而中断阻塞中的命令，
+	// 因此不能无限阻塞，否则 Stop 无法及时生效
+	consumeBlockTimeout = time.Second
+)
+
 // id 消费者需要通过此Id来判断该消息是否已被消费
 type ConsumeMsgHandler func(ctx context.Context, id string, msg map[string]any) error
 
+// 批量消费消息的处理器，failed 为处理失败的消息Id，这些消息不会被ACK，需要重试，其余消息会被ACK
+// err 非nil且 failed 为空时，表示这一批消息全部处理失败
+type ConsumeBatchHandler func(ctx context.Context, msgs []redis.XMessage) (failed []string, err error)
+
 type MessageQueue interface {
 	Publish(ctx context.Context, topic string, body map[string]any) error
 	Subscribe(ctx context.Context, topic, group, consumer string, handler ConsumeMsgHandler) error
@@ -41,16 +57,20 @@ func (m *RedisMessageQueue) Close() {
 	if m.client == nil {
 		return
 	}
-	m.closeChan <- Empty{}
+	close(m.closeChan) // 通知所有消费者退出
 	m.client.Close()
 	m.client = nil
 }
 
 // 发布消息
 func (m *RedisMessageQueue) Publish(ctx context.Context, topic string, body map[string]any) error {
+	return m.publish(ctx, topic, body, int64(m.xaddMaxLen))
+}
+
+func (m *RedisMessageQueue) publish(ctx context.Context, topic string, body map[string]any, maxLen int64) error {
 	res := m.client.XAdd(ctx, &redis.XAddArgs{
 		Stream: topic,
-		MaxLen: int64(m.xaddMaxLen),
+		MaxLen: maxLen,
 		Approx: true,
 		ID:     "*", // 让Redis生成时间戳和序列号
 		Values: body,
@@ -58,66 +78,397 @@ func (m *RedisMessageQueue) Publish(ctx context.Context, topic string, body map[
 	return res.Err()
 }
 
-// 开启协程后台消费。返回值代表消费过程中遇到的无法处理的错误
+// 类型化消息在 stream 中存放 JSON 的字段名
+const typedMessageField = "payload"
+
+// 将 msg 编码为 JSON 后发布，与 ConsumeTyped 配合使用
+// maxLen 为 stream 的最大长度（近似裁剪），<=0 时使用创建队列时的 xaddMaxLen
+func PublishTyped[T any](ctx context.Context, m *RedisMessageQueue, topic string, msg T, maxLen int64) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if maxLen <= 0 {
+		maxLen = int64(m.xaddMaxLen)
+	}
+	return m.publish(ctx, topic, map[string]any{typedMessageField: string(data)}, maxLen)
+}
+
+// 开启协程后台消费 PublishTyped 发布的消息，handler 收到的是解码后的消息，其余同 Subscribe
+// batchSize 为每次拉取的消息数量，<=0 时使用创建队列时的 batchSize
+// 解码失败的消息视为处理失败，不会被ACK，建议配合 WithDeadLetter 使用
+func ConsumeTyped[T any](ctx context.Context, m *RedisMessageQueue, topic, group, consumer string, batchSize int, handler func(id string, msg T) error, opts ...ConsumeOption) error {
+	if batchSize > 0 {
+		opts = append(opts, func(c *Consumer) { c.batchSize = batchSize })
+	}
+	_, err := m.Consume(ctx, topic, group, consumer, func(ctx context.Context, id string, values map[string]any) error {
+		raw, ok := values[typedMessageField].(string)
+		if !ok {
+			return fmt.Errorf("message %s has no %s field", id, typedMessageField)
+		}
+		var msg T
+		if err := json.Unmarshal([]byte(raw), &msg); err != nil {
+			return err
+		}
+		return handler(id, msg)
+	}, opts...)
+	return err
+}
+
+// 开启协程后台消费。返回值代表启动消费时遇到的错误
 // group 消费者组，一般为当前服务的名称
 // consumer 消费者组里的消费者，一般为一个uuid
 // handler 消费消息的处理器，如果返回nil，则表示消息被成功消费，如果返回非nil，则表示消息被消费失败，需要重试
 func (m *RedisMessageQueue) Subscribe(ctx context.Context, topic, group, consumer string, handler ConsumeMsgHandler) error {
-	res := m.client.XGroupCreateMkStream(ctx, topic, group, "0") // start 用于创建消费者组的时候指定起始消费ID，0表示从头开始消费，$表示从最后一条消息开始消费
+	_, err := m.Consume(ctx, topic, group, consumer, handler)
+	return err
+}
+
+// 消费的可选配置
+type ConsumeOption func(*Consumer)
+
+// 启用死信：消息投递次数达到 maxRetries 后仍处理失败，则将其转移到死信队列 stream 并ACK原消息，
+// 避免处理不了的消息一直被重复投递。stream 为空时使用 "<topic>:dead"
+// 死信消息保留原消息的所有字段，并额外增加 deadLetterOriginIdField 字段记录原消息Id
+func WithDeadLetter(stream string, maxRetries int) ConsumeOption {
+	return func(c *Consumer) {
+		c.deadLetterStream = stream
+		c.maxRetries = maxRetries
+	}
+}
+
+const deadLetterOriginIdField = "_origin_id"
+
+// 启用待确认消息的认领：后台定期通过 XAUTOCLAIM 将组内任意消费者名下、空闲超过 minIdle 的消息转到当前消费者名下，
+// 用于消费者进程崩溃（且重启后消费者名称变化）时，其未ACK的消息不会永远滞留
+// 认领只转移归属（JUSTID），消息仍由主循环拉取待确认消息时处理，同一条消息不会被并发处理
+func WithReclaim(minIdle time.Duration) ConsumeOption {
+	return func(c *Consumer) {
+		c.reclaimMinIdle = minIdle
+	}
+}
+
+// 不需要ACK：消息投递后即视为已消费，不会进入待确认列表。吞吐量更高，但处理失败或进程崩溃时消息会丢失
+// 此时 WithDeadLetter、WithReclaim 不再生效
+func WithNoAck() ConsumeOption {
+	return func(c *Consumer) {
+		c.noAck = true
+	}
+}
+
+// 同 Subscribe，但返回消费者句柄，可用于停止消费以及获取消费过程中遇到的错误
+func (m *RedisMessageQueue) Consume(ctx context.Context, topic, group, consumer string, handler ConsumeMsgHandler, opts ...ConsumeOption) (*Consumer, error) {
+	return m.startConsumer(ctx, topic, group, consumer, &Consumer{handler: handler}, opts...)
+}
+
+// 同 Consume，但每次拉取到的一批消息交给 handler 一次性处理，适用于批量写库等场景
+func (m *RedisMessageQueue) ConsumeBatch(ctx context.Context, topic, group, consumer string, handler ConsumeBatchHandler, opts ...ConsumeOption) (*Consumer, error) {
+	return m.startConsumer(ctx, topic, group, consumer, &Consumer{batchHandler: handler}, opts...)
+}
+
+func (m *RedisMessageQueue) startConsumer(ctx context.Context, topic, group, consumer string, c *Consumer, opts ...ConsumeOption) (*Consumer, error) {
+	m.mutex.RLock()
+	client := m.client
+	m.mutex.RUnlock()
+	if client == nil {
+		return nil, errors.New("message queue closed")
+	}
+
+	res := client.XGroupCreateMkStream(ctx, topic, group, "0") // start 用于创建消费者组的时候指定起始消费ID，0表示从头开始消费，$表示从最后一条消息开始消费
 	err := res.Err()
 	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
-		return err
+		return nil, err
 	}
-	return m.pool.Submit(func() {
-		for {
-			select {
-			case <-m.closeChan:
-				return
-			case <-ctx.Done():
-				return
-			default:
-				// 拉取新消息
-				if err := m.consume(ctx, topic, group, consumer, ">", m.batchSize, handler); err != nil {
-					continue
-				}
-				// 拉取已经投递却未被ACK的消息，保证消息至少被成功消费1次
-				if err := m.consume(ctx, topic, group, consumer, "0", m.batchSize, handler); err != nil {
-					continue
-				}
-			}
+
+	readCtx, cancel := context.WithCancel(ctx)
+	c.client = client
+	c.topic = topic
+	c.group = group
+	c.name = consumer
+	c.batchSize = m.batchSize
+	c.closeChan = m.closeChan
+	c.readCtx = readCtx
+	c.cancel = cancel
+	c.done = make(chan Empty)
+	c.errChan = make(chan error, 16)
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.noAck {
+		c.maxRetries = 0
+		c.reclaimMinIdle = 0
+	}
+	if c.maxRetries > 0 && len(c.deadLetterStream) == 0 {
+		c.deadLetterStream = topic + ":dead"
+	}
+	c.wg.Add(1)
+	err = m.pool.Submit(func() { c.run(ctx) })
+	if err != nil {
+		c.wg.Done()
+		cancel()
+		return nil, err
+	}
+	if c.reclaimMinIdle > 0 {
+		c.wg.Add(1)
+		err = m.pool.Submit(func() { c.reclaimLoop() })
+		if err != nil {
+			c.wg.Done()
+			cancel()
+			c.wg.Wait()
+			return nil, err
 		}
-	})
+	}
+	go func() {
+		c.wg.Wait()
+		close(c.done)
+	}()
+	return c, nil
+}
+
+// 消费者句柄
+type Consumer struct {
+	client    *redis.Client
+	topic     string
+	group     string
+	name      string
+	batchSize int
+	closeChan chan Empty
+
+	handler      ConsumeMsgHandler
+	batchHandler ConsumeBatchHandler
+	noAck        bool
+
+	deadLetterStream string
+	maxRetries       int
+	reclaimMinIdle   time.Duration
+
+	readCtx  context.Context // 取消后不再拉取新的消息
+	cancel   context.CancelFunc
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+	done     chan Empty
+	errChan  chan error
+}
+
+// 消费过程中遇到的错误，如拉取消息失败、ACK失败等。通道满时新的错误会被丢弃
+func (c *Consumer) Errors() <-chan error { return c.errChan }
+
+// 停止拉取新的消息，等待当前这一批消息处理完成后返回
+// ctx 结束时不再等待，直接返回 ctx 的错误
+func (c *Consumer) Stop(ctx context.Context) error {
+	c.stopOnce.Do(c.cancel)
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Consumer) reportError(err error) {
+	select {
+	case c.errChan <- err:
+	default:
+	}
+}
+
+func (c *Consumer) stopped() bool {
+	select {
+	case <-c.closeChan:
+		return true
+	case <-c.readCtx.Done():
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *Consumer) run(ctx context.Context) {
+	defer c.wg.Done()
+	for !c.stopped() {
+		// 拉取新消息
+		if err := c.consume(ctx, ">"); err != nil {
+			c.onConsumeError(err)
+			continue
+		}
+		if c.noAck {
+			continue
+		}
+		// 拉取已经投递却未被ACK的消息，保证消息至少被成功消费1次
+		if err := c.consume(ctx, "0"); err != nil {
+			c.onConsumeError(err)
+			continue
+		}
+	}
+}
+
+func (c *Consumer) onConsumeError(err error) {
+	if c.stopped() {
+		return
+	}
+	c.reportError(err)
+	select {
+	case <-c.closeChan:
+	case <-c.readCtx.Done():
+	case <-time.After(consumeErrorBackoff):
+	}
 }
 
-func (m *RedisMessageQueue) consume(ctx context.Context, topic, group, consumer, id string, batchSize int, h ConsumeMsgHandler) error {
+// ctx 用于处理消息与ACK，readCtx 仅用于拉取消息，Stop 时不会中断正在处理的消息
+func (c *Consumer) consume(ctx context.Context, id string) error {
 	// 阻塞的获取消息
-	result, err := m.client.XReadGroup(ctx, &redis.XReadGroupArgs{
-		Group:    group,
-		Consumer: consumer,
-		Streams:  []string{topic, id},
-		Count:    int64(batchSize),
-		NoAck:    false,
+	result, err := c.client.XReadGroup(c.readCtx, &redis.XReadGroupArgs{
+		Group:    c.group,
+		Consumer: c.name,
+		Streams:  []string{c.topic, id},
+		Count:    int64(c.batchSize),
+		Block:    consumeBlockTimeout,
+		NoAck:    c.noAck,
 	}).Result()
-	if err != nil {
+	if errors.Is(err, redis.Nil) {
+		return nil
+	} else if err != nil {
 		return err
 	}
-	// 处理消息
-	for _, msg := range result[0].Messages {
+	if len(result) == 0 {
+		return nil
+	}
+	c.handleMessages(ctx, result[0].Messages)
+	return nil
+}
+
+func (c *Consumer) reclaimLoop() {
+	defer c.wg.Done()
+	ticker := time.NewTicker(c.reclaimMinIdle)
+	defer ticker.Stop()
+	for {
 		select {
-		case <-m.closeChan:
-			return nil
-		case <-ctx.Done():
-			return nil
-		default:
-			err := h(ctx, msg.ID, msg.Values)
-			if err != nil {
+		case <-c.closeChan:
+			return
+		case <-c.readCtx.Done():
+			return
+		case <-ticker.C:
+			c.reclaim()
+		}
+	}
+}
+
+// 遍历整个待确认列表，将空闲超时的消息认领到当前消费者名下，由 run 中拉取待确认消息时处理
+// 在这里处理会与 run 同时处理当前消费者名下的同一条消息
+func (c *Consumer) reclaim() {
+	start := "0-0"
+	for !c.stopped() {
+		_, next, err := c.client.XAutoClaimJustID(c.readCtx, &redis.XAutoClaimArgs{
+			Stream:   c.topic,
+			Group:    c.group,
+			MinIdle:  c.reclaimMinIdle,
+			Start:    start,
+			Count:    int64(c.batchSize),
+			Consumer: c.name,
+		}).Result()
+		if err != nil {
+			if !c.stopped() {
+				c.reportError(err)
+			}
+			return
+		}
+		if next == "0-0" {
+			return
+		}
+		start = next
+	}
+}
+
+// 处理一批消息，处理成功的消息在最后通过一次 XACK 批量确认
+func (c *Consumer) handleMessages(ctx context.Context, msgs []redis.XMessage) {
+	if len(msgs) == 0 {
+		return
+	}
+	if c.batchHandler != nil {
+		failed, err := c.batchHandler(ctx, msgs)
+		if err != nil && len(failed) == 0 {
+			for _, msg := range msgs {
+				c.deadLetterIfExhausted(ctx, msg)
+			}
+			return
+		}
+		failedSet := make(map[string]Empty, len(failed))
+		for _, id := range failed {
+			failedSet[id] = Empty{}
+		}
+		succeeded := make([]redis.XMessage, 0, len(msgs))
+		for _, msg := range msgs {
+			if _, ok := failedSet[msg.ID]; ok {
+				c.deadLetterIfExhausted(ctx, msg)
 				continue
 			}
-			err = m.client.XAck(ctx, topic, group, msg.ID).Err()
+			succeeded = append(succeeded, msg)
+		}
+		c.ack(ctx, succeeded...)
+		return
+	}
+
+	succeeded := make([]redis.XMessage, 0, len(msgs))
+	defer func() { c.ack(ctx, succeeded...) }() // 中途退出时，已经处理成功的消息也需要ACK
+	for _, msg := range msgs {
+		select {
+		case <-c.closeChan:
+			return
+		case <-ctx.Done():
+			return
+		default:
+			err := c.handler(ctx, msg.ID, msg.Values)
 			if err != nil {
+				c.deadLetterIfExhausted(ctx, msg)
 				continue
 			}
+			succeeded = append(succeeded, msg)
 		}
 	}
-	return nil
+}
+
+func (c *Consumer) ack(ctx context.Context, msgs ...redis.XMessage) {
+	if c.noAck || len(msgs) == 0 {
+		return
+	}
+	ids := make([]string, len(msgs))
+	for i := range msgs {
+		ids[i] = msgs[i].ID
+	}
+	if err := c.client.XAck(context.WithoutCancel(ctx), c.topic, c.group, ids...).Err(); err != nil {
+		c.reportError(err)
+	}
+}
+
+// 投递次数达到上限时，将消息转移到死信队列
+func (c *Consumer) deadLetterIfExhausted(ctx context.Context, msg redis.XMessage) {
+	if c.maxRetries <= 0 {
+		return
+	}
+	pending, err := c.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: c.topic,
+		Group:  c.group,
+		Start:  msg.ID,
+		End:    msg.ID,
+		Count:  1,
+	}).Result()
+	if err != nil {
+		c.reportError(err)
+		return
+	}
+	if len(pending) == 0 || pending[0].RetryCount < int64(c.maxRetries) {
+		return
+	}
+
+	values := make(map[string]any, len(msg.Values)+1)
+	for k, v := range msg.Values {
+		values[k] = v
+	}
+	values[deadLetterOriginIdField] = msg.ID
+	pipe := c.client.TxPipeline()
+	pipe.XAdd(ctx, &redis.XAddArgs{Stream: c.deadLetterStream, ID: "*", Values: values})
+	pipe.XAck(ctx, c.topic, c.group, msg.ID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		c.reportError(err)
+	}
 }
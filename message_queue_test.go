@@ -0,0 +1,309 @@
+package niu
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestMessageQueue(t *testing.T) (*RedisMessageQueue, *redis.Client) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	pool, err := NewDefaultPool(64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := NewRedisMessageQueue(context.Background(), &redis.Options{Addr: mr.Addr()}, pool, 1000, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(q.Close)
+	return q, redis.NewClient(&redis.Options{Addr: mr.Addr()})
+}
+
+func pendingCount(t *testing.T, client *redis.Client, topic, group string) int64 {
+	t.Helper()
+	p, err := client.XPending(context.Background(), topic, group).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p.Count
+}
+
+func TestReclaimFromCrashedConsumer(t *testing.T) {
+	q, client := newTestMessageQueue(t)
+	ctx := context.Background()
+
+	crashed, err := q.Consume(ctx, "t", "g", "crashed", func(context.Context, string, map[string]any) error {
+		return errors.New("crash")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Publish(ctx, "t", map[string]any{"a": "1"}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	crashed.Stop(ctx)
+
+	var handled atomic.Int32
+	alive, err := q.Consume(ctx, "t", "g", "alive", func(context.Context, string, map[string]any) error {
+		handled.Add(1)
+		return nil
+	}, WithReclaim(50*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(1500 * time.Millisecond)
+	alive.Stop(ctx)
+
+	if n := handled.Load(); n != 1 {
+		t.Fatalf("handled %d times, want 1", n)
+	}
+	if n := pendingCount(t, client, "t", "g"); n != 0 {
+		t.Fatalf("pending = %d", n)
+	}
+}
+
+// 自己名下空闲超时的消息不能同时被主循环与认领处理
+func TestReclaimDoesNotProcessConcurrently(t *testing.T) {
+	q, _ := newTestMessageQueue(t)
+	ctx := context.Background()
+
+	var (
+		mutex    sync.Mutex
+		inFlight = map[string]int{}
+		overlap  atomic.Bool
+		attempts atomic.Int32
+	)
+	c, err := q.Consume(ctx, "t", "g", "c", func(ctx context.Context, id string, msg map[string]any) error {
+		mutex.Lock()
+		inFlight[id]++
+		if inFlight[id] > 1 {
+			overlap.Store(true)
+		}
+		mutex.Unlock()
+		defer func() {
+			mutex.Lock()
+			inFlight[id]--
+			mutex.Unlock()
+		}()
+
+		time.Sleep(60 * time.Millisecond)
+		if attempts.Add(1) < 5 {
+			return errors.New("retry")
+		}
+		return nil
+	}, WithReclaim(20*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Publish(ctx, "t", map[string]any{"a": "1"})
+	time.Sleep(1500 * time.Millisecond)
+	c.Stop(ctx)
+
+	if overlap.Load() {
+		t.Fatal("the same message was handled concurrently")
+	}
+}
+
+func TestDeadLetter(t *testing.T) {
+	tests := []struct {
+		name       string
+		stream     string
+		failures   int32 // 前几次处理失败
+		wantCalls  int32
+		wantDead   bool
+		deadStream string
+	}{
+		{"always failing", "", 100, 3, true, "t:dead"},
+		{"custom stream", "custom", 100, 3, true, "custom"},
+		{"succeeds before limit", "", 2, 3, false, "t:dead"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			q, client := newTestMessageQueue(t)
+			ctx := context.Background()
+			var calls atomic.Int32
+			c, err := q.Consume(ctx, "t", "g", "c", func(context.Context, string, map[string]any) error {
+				if calls.Add(1) <= tt.failures {
+					return errors.New("fail")
+				}
+				return nil
+			}, WithDeadLetter(tt.stream, 3))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := q.Publish(ctx, "t", map[string]any{"k": "v"}); err != nil {
+				t.Fatal(err)
+			}
+			waitFor(t, func() bool { return calls.Load() >= tt.wantCalls })
+			time.Sleep(50 * time.Millisecond)
+			c.Stop(ctx)
+
+			if n := calls.Load(); n != tt.wantCalls {
+				t.Fatalf("handled %d times, want %d", n, tt.wantCalls)
+			}
+			if n := pendingCount(t, client, "t", "g"); n != 0 {
+				t.Fatalf("pending = %d", n)
+			}
+			dead, _ := client.XRange(ctx, tt.deadStream, "-", "+").Result()
+			if !tt.wantDead {
+				if len(dead) != 0 {
+					t.Fatalf("dead letters = %v", dead)
+				}
+				return
+			}
+			if len(dead) != 1 || dead[0].Values["k"] != "v" || dead[0].Values[deadLetterOriginIdField] == "" {
+				t.Fatalf("dead letters = %v", dead)
+			}
+		})
+	}
+}
+
+func TestConsumeTypedDecodeFailureDeadLetters(t *testing.T) {
+	type order struct {
+		Id    int    `json:"id"`
+		Title string `json:"title"`
+	}
+	q, client := newTestMessageQueue(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	got := make(chan order, 1)
+	err := ConsumeTyped(ctx, q, "t", "g", "c", 5, func(_ string, msg order) error {
+		got <- msg
+		return nil
+	}, WithDeadLetter("", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q.Publish(ctx, "t", map[string]any{typedMessageField: "not json"})
+	if err := PublishTyped(ctx, q, "t", order{1, "a"}, 0); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-got:
+		if msg != (order{1, "a"}) {
+			t.Fatalf("got %+v", msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("typed message not handled")
+	}
+	waitFor(t, func() bool {
+		n, _ := client.XLen(ctx, "t:dead").Result()
+		return n == 1
+	})
+}
+
+func TestPublishTypedMaxLen(t *testing.T) {
+	q, client := newTestMessageQueue(t)
+	ctx := context.Background()
+	for i := range 5 {
+		if err := PublishTyped(ctx, q, "t", i, 2); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// miniredis 忽略近似裁剪，按 maxLen 精确裁剪
+	msgs, err := client.XRange(ctx, "t", "-", "+").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 || msgs[1].Values[typedMessageField] != "4" {
+		t.Fatalf("stream = %v, want the last 2 messages", msgs)
+	}
+}
+
+func TestConsumeBatchAck(t *testing.T) {
+	tests := []struct {
+		name        string
+		failed      []int // 处理失败的消息下标
+		err         error
+		opts        []ConsumeOption
+		wantPending int64
+	}{
+		{"all succeeded", nil, nil, nil, 0},
+		{"partial failure", []int{2, 5}, errors.New("insert failed"), nil, 2},
+		{"whole batch failed", nil, errors.New("db down"), nil, 10},
+		{"no ack", []int{2, 5}, errors.New("insert failed"), []ConsumeOption{WithNoAck()}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			q, client := newTestMessageQueue(t)
+			ctx := context.Background()
+			for i := range 10 {
+				if err := q.Publish(ctx, "t", map[string]any{"i": i}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var calls atomic.Int32
+			var batchLen atomic.Int32
+			c, err := q.ConsumeBatch(ctx, "t", "g", "c", func(_ context.Context, msgs []redis.XMessage) ([]string, error) {
+				if calls.Add(1) == 1 {
+					batchLen.Store(int32(len(msgs)))
+				}
+				var failed []string
+				for _, i := range tt.failed {
+					if i < len(msgs) {
+						failed = append(failed, msgs[i].ID)
+					}
+				}
+				return failed, tt.err
+			}, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			waitFor(t, func() bool { return calls.Load() >= 1 })
+			c.Stop(ctx)
+
+			if n := batchLen.Load(); n != 10 {
+				t.Fatalf("first batch has %d messages, want 10", n)
+			}
+			if n := pendingCount(t, client, "t", "g"); n != tt.wantPending {
+				t.Fatalf("pending = %d, want %d", n, tt.wantPending)
+			}
+		})
+	}
+}
+
+// 部分失败时只有失败的消息会进入死信队列
+func TestConsumeBatchDeadLettersFailedOnly(t *testing.T) {
+	q, client := newTestMessageQueue(t)
+	ctx := context.Background()
+	for i := range 3 {
+		q.Publish(ctx, "t", map[string]any{"i": i})
+	}
+	c, err := q.ConsumeBatch(ctx, "t", "g", "c", func(_ context.Context, msgs []redis.XMessage) ([]string, error) {
+		var failed []string
+		for _, msg := range msgs {
+			if msg.Values["i"] == "1" {
+				failed = append(failed, msg.ID)
+			}
+		}
+		return failed, errors.New("fail")
+	}, WithDeadLetter("", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool {
+		n, _ := client.XLen(ctx, "t:dead").Result()
+		return n == 1
+	})
+	c.Stop(ctx)
+
+	dead, _ := client.XRange(ctx, "t:dead", "-", "+").Result()
+	if len(dead) != 1 || dead[0].Values["i"] != "1" {
+		t.Fatalf("dead letters = %v", dead)
+	}
+	if n := pendingCount(t, client, "t", "g"); n != 0 {
+		t.Fatalf("pending = %d", n)
+	}
+}
@@ -1,10 +1,15 @@
 package niu
 
 import (
+	"encoding/json"
+	"errors"
 	"slices"
 	"strconv"
+	"strings"
 )
 
+var ErrInvalidPlatform = errors.New("invalid platform")
+
 type Platform int8
 
 const (
@@ -20,33 +25,99 @@ const (
 	Harmony    Platform = 9
 )
 
-var Platforms = []Platform{Android, AndroidPad, IPhone, IPad, Mac, IPad, Windows, Linux, Web, Harmony}
+// 所有有效的平台，每个平台只出现一次，按常量值排序
+var Platforms = []Platform{Android, AndroidPad, IPhone, Mac, IPad, Windows, Linux, Web, Harmony}
 
-func IsPlatformValid(p Platform) bool {
-	return slices.Contains(Platforms, p)
+var platformNames = map[Platform]string{
+	Unspecify:  "Unspecify",
+	Android:    "Android",
+	AndroidPad: "AndroidPad",
+	IPhone:     "iPhone",
+	Mac:        "Mac",
+	IPad:       "iPad",
+	Windows:    "Windows",
+	Linux:      "Linux",
+	Web:        "Web",
+	Harmony:    "Harmony",
 }
 
-func ParsePlatform(pstr string) Platform {
-	if !IsPlatformStringValid(pstr) {
-		return Unspecify
+func (p Platform) String() string {
+	if name, ok := platformNames[p]; ok {
+		return name
 	}
+	return "Platform(" + strconv.Itoa(int(p)) + ")"
+}
 
-	p, err := strconv.Atoi(pstr)
-	if err != nil {
-		return Unspecify
+// 已知的平台序列化为名称，未知的值序列化为数字，UnmarshalJSON 可以原样读回
+func (p Platform) MarshalJSON() ([]byte, error) {
+	if name, ok := platformNames[p]; ok {
+		return json.Marshal(name)
 	}
-	return Platform(p)
+	return []byte(strconv.Itoa(int(p))), nil
 }
 
-func IsPlatformStringValid(pstr string) bool {
+// 支持数字、名称（不区分大小写）以及数字字符串
+// int8 范围内的数字均可读回（包括未知的平台），以便与 MarshalJSON 对称，是否有效需使用 IsPlatformValid 判断
+func (p *Platform) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	var str string
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &str); err != nil {
+			return err
+		}
+	} else {
+		str = string(data)
+	}
+
+	if n, err := strconv.ParseInt(str, 10, 8); err == nil {
+		*p = Platform(n)
+		return nil
+	}
+	v, ok := parsePlatform(str)
+	if !ok {
+		return ErrInvalidPlatform
+	}
+	*p = v
+	return nil
+}
+
+func IsPlatformValid(p Platform) bool {
+	return slices.Contains(Platforms, p)
+}
+
+// 解析平台，支持数字字符串（如 "3"）和名称（如 "iPhone"，不区分大小写）
+// 仅当结果为 Platforms 中的有效平台时返回 true
+func ParsePlatform(pstr string) (Platform, bool) {
+	p, ok := parsePlatform(pstr)
+	if !ok || !IsPlatformValid(p) {
+		return Unspecify, false
+	}
+	return p, true
+}
+
+// 与 ParsePlatform 相同，但允许 Unspecify
+func parsePlatform(pstr string) (Platform, bool) {
 	if len(pstr) == 0 {
-		return false
+		return Unspecify, false
+	}
+
+	if n, err := strconv.ParseInt(pstr, 10, 8); err == nil {
+		p := Platform(n)
+		return p, p == Unspecify || IsPlatformValid(p)
 	}
 
-	p, err := strconv.Atoi(pstr)
-	if err != nil {
-		return false
+	for p, name := range platformNames {
+		if strings.EqualFold(name, pstr) {
+			return p, true
+		}
 	}
+	return Unspecify, false
+}
 
-	return slices.Contains(Platforms, Platform(p))
+func IsPlatformStringValid(pstr string) bool {
+	_, ok := ParsePlatform(pstr)
+	return ok
 }
@@ -0,0 +1,125 @@
+package niu
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestPlatformJsonRoundTrip(t *testing.T) {
+	tests := []struct {
+		platform Platform
+		json     string
+	}{
+		{IPhone, `"iPhone"`},
+		{Web, `"Web"`},
+		{Unspecify, `"Unspecify"`},
+		{Platform(42), `42`},
+		{Platform(-3), `-3`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.platform.String(), func(t *testing.T) {
+			data, err := json.Marshal(tt.platform)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.json {
+				t.Fatalf("Marshal = %s, want %s", data, tt.json)
+			}
+			var out Platform
+			if err := json.Unmarshal(data, &out); err != nil {
+				t.Fatal(err)
+			}
+			if out != tt.platform {
+				t.Fatalf("Unmarshal = %d, want %d", out, tt.platform)
+			}
+		})
+	}
+}
+
+func TestPlatformUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Platform
+		wantErr bool
+	}{
+		{`3`, IPhone, false},
+		{`"3"`, IPhone, false},
+		{`"iphone"`, IPhone, false},
+		{`"HARMONY"`, Harmony, false},
+		{`"Symbian"`, 0, true},
+		{`300`, 0, true},
+		{`""`, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			var out Platform
+			err := json.Unmarshal([]byte(tt.input), &out)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && out != tt.want {
+				t.Fatalf("got %d, want %d", out, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePlatform(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Platform
+		ok    bool
+	}{
+		{"8", Web, true},
+		{"ipad", IPad, true},
+		{"0", Unspecify, false},
+		{"Unspecify", Unspecify, false},
+		{"42", Unspecify, false},
+		{"", Unspecify, false},
+	}
+	for _, tt := range tests {
+		p, ok := ParsePlatform(tt.input)
+		if p != tt.want || ok != tt.ok {
+			t.Errorf("ParsePlatform(%q) = %d, %v; want %d, %v", tt.input, p, ok, tt.want, tt.ok)
+		}
+	}
+	seen := map[Platform]bool{}
+	for i, p := range Platforms {
+		if seen[p] || (i > 0 && Platforms[i-1] >= p) {
+			t.Fatalf("Platforms not unique and ordered: %v", Platforms)
+		}
+		seen[p] = true
+	}
+}
+
+// Platforms 需包含除 Unspecify 外的所有 Platform 常量，新增常量时同步更新
+func TestPlatformsCount(t *testing.T) {
+	f, err := parser.ParseFile(token.NewFileSet(), "platform.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := map[string]bool{}
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.CONST {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			vs := spec.(*ast.ValueSpec)
+			if ident, ok := vs.Type.(*ast.Ident); !ok || ident.Name != "Platform" {
+				continue
+			}
+			for i, name := range vs.Names {
+				if name.Name != "Unspecify" {
+					values[vs.Values[i].(*ast.BasicLit).Value] = true
+				}
+			}
+		}
+	}
+	if len(values) == 0 || len(Platforms) != len(values) {
+		t.Fatalf("len(Platforms) = %d, want %d distinct Platform constants", len(Platforms), len(values))
+	}
+}
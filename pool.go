@@ -2,14 +2,82 @@ package niu
 
 import (
 	"bytes"
+	"errors"
+	"log"
+	"runtime/debug"
 	"sync"
+	"sync/atomic"
 )
 
+// 协程池，如 ants.Pool 可直接使用
 type CoroutinePool interface {
 	Submit(task func()) error
 	Release()
 }
 
+var (
+	ErrPoolFull   = errors.New("coroutine pool is full")
+	ErrPoolClosed = errors.New("coroutine pool is closed")
+)
+
+// 基于标准库的默认协程池，最多同时运行 size 个任务，已满时 Submit 立即返回 ErrPoolFull
+// 用于 Hub 时每个连接的读写协程会一直占用 2 个名额，size 应大于最大连接数的 2 倍
+type defaultPool struct {
+	sem          chan Empty
+	closed       atomic.Bool
+	panicHandler func(r any)
+}
+
+type DefaultPoolOption func(*defaultPool)
+
+// 任务 panic 时调用，参数为 recover 得到的值，可用于上报或重新 panic
+// 未设置时使用标准库 log 记录 panic 及其调用栈，与 ants 一致不会导致进程退出；fn 为 nil 时忽略 panic
+func WithPoolPanicHandler(fn func(r any)) DefaultPoolOption {
+	return func(p *defaultPool) {
+		p.panicHandler = fn
+	}
+}
+
+func NewDefaultPool(size int, opts ...DefaultPoolOption) (CoroutinePool, error) {
+	if size <= 0 {
+		return nil, errors.New("pool size must be positive")
+	}
+	p := &defaultPool{sem: make(chan Empty, size), panicHandler: logTaskPanic}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p, nil
+}
+
+func (p *defaultPool) Submit(task func()) error {
+	if p.closed.Load() {
+		return ErrPoolClosed
+	}
+	select {
+	case p.sem <- Empty{}:
+	default:
+		return ErrPoolFull
+	}
+	go func() {
+		defer func() {
+			<-p.sem
+			if r := recover(); r != nil && p.panicHandler != nil {
+				p.panicHandler(r)
+			}
+		}()
+		task()
+	}()
+	return nil
+}
+
+// 在 recover 所在的 defer 中调用，debug.Stack 仍能得到 panic 发生处的调用栈
+func logTaskPanic(r any) {
+	log.Printf("[Pool] task panic: %v\n%s", r, debug.Stack())
+}
+
+// 之后提交的任务返回 ErrPoolClosed，正在运行的任务不受影响
+func (p *defaultPool) Release() { p.closed.Store(true) }
+
 type BytePool struct{ p sync.Pool }
 
 func NewBytePool(size, cap int) *BytePool {
@@ -25,18 +93,27 @@ func (p *BytePool) Get() []byte { return p.p.Get().([]byte) }
 func (p *BytePool) Put(b []byte) { p.p.Put(b[:0]) } // 重置已用长度
 
 type ByteBufferPool struct {
-	p sync.Pool
+	p      sync.Pool
+	gets   atomic.Int64
+	misses atomic.Int64 // 池中没有可复用的缓冲而新建的次数
 }
 
 func NewByteBufferPool(size, cap int) *ByteBufferPool {
-	return &ByteBufferPool{
-		p: sync.Pool{
-			New: func() any { return bytes.NewBuffer(make([]byte, size, cap)) },
-		},
+	p := &ByteBufferPool{}
+	p.p.New = func() any {
+		p.misses.Add(1)
+		return bytes.NewBuffer(make([]byte, size, cap))
 	}
+	return p
+}
+
+// 返回获取缓冲的总次数与其中新建缓冲的次数，可据此计算命中率
+func (p *ByteBufferPool) Stats() (gets, misses int64) {
+	return p.gets.Load(), p.misses.Load()
 }
 
 func (p *ByteBufferPool) Get() *bytes.Buffer {
+	p.gets.Add(1)
 	b := p.p.Get().(*bytes.Buffer)
 	b.Reset()
 	return b
@@ -0,0 +1,166 @@
+package niu
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestDefaultPoolSubmit(t *testing.T) {
+	if _, err := NewDefaultPool(0); err == nil {
+		t.Fatal("size 0 should fail")
+	}
+	pool, err := NewDefaultPool(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := make(chan Empty)
+	for range 2 {
+		if err := pool.Submit(func() { <-block }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pool.Submit(func() {}); !errors.Is(err, ErrPoolFull) {
+		t.Fatalf("full pool: %v, want ErrPoolFull", err)
+	}
+	close(block)
+
+	// 任务结束后名额归还
+	done := make(chan Empty)
+	waitFor(t, func() bool { return pool.Submit(func() { close(done) }) == nil })
+	<-done
+
+	pool.Release()
+	if err := pool.Submit(func() {}); !errors.Is(err, ErrPoolClosed) {
+		t.Fatalf("released pool: %v, want ErrPoolClosed", err)
+	}
+}
+
+func TestDefaultPoolPanicHandler(t *testing.T) {
+	got := make(chan any, 1)
+	pool, err := NewDefaultPool(1, WithPoolPanicHandler(func(r any) { got <- r }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Submit(func() { panic("boom") }); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case r := <-got:
+		if r != "boom" {
+			t.Fatalf("recovered %v", r)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("panic handler not called")
+	}
+	// panic 的任务同样归还名额
+	waitFor(t, func() bool { return pool.Submit(func() {}) == nil })
+
+	// 未设置处理函数时记录 panic 及调用栈
+	var buf bytes.Buffer
+	var mutex sync.Mutex
+	prev := log.Writer()
+	defer log.SetOutput(prev)
+	log.SetOutput(writerFunc(func(p []byte) (int, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return buf.Write(p)
+	}))
+	logged, _ := NewDefaultPool(1)
+	if err := logged.Submit(func() { panic("logged") }); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return logged.Submit(func() {}) == nil })
+	mutex.Lock()
+	out := buf.String()
+	mutex.Unlock()
+	if !strings.Contains(out, "task panic: logged") || !strings.Contains(out, "TestDefaultPoolPanicHandler") {
+		t.Fatalf("log output = %q, want the panic value and stack", out)
+	}
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+// 协程池没有空闲名额时推送改为同步执行，所有消息都按顺序送达
+func TestHubPushWithFullPool(t *testing.T) {
+	// 空闲检测与连接的读写协程正好占满 3 个名额，推送任务都提交失败
+	pool, err := NewDefaultPool(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Release)
+	h, err := NewHub(nil, time.Second, time.Hour, time.Minute, time.Second, pool, time.Second, false,
+		func(*http.Request) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := newTestHubServer(t, h)
+	c := dialTestHub(t, url+"?u=u1&id=l1")
+	waitFor(t, func() bool { return h.LiveCount() == 1 })
+	if err := pool.Submit(func() {}); !errors.Is(err, ErrPoolFull) {
+		t.Fatalf("pool should be full: %v", err)
+	}
+
+	const n = 100
+	for i := range n {
+		if i%2 == 0 {
+			err = h.PushMessage([]string{"u1"}, []byte{byte(i)})
+		} else {
+			err = h.BroadcastMessage([]byte{byte(i)})
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := readTestMessages(t, c, n)
+	for i := range n {
+		if got[i] != byte(i) {
+			t.Fatalf("message %d = %d", i, got[i])
+		}
+	}
+	if fallbacks := h.Stats().SubmitFallbacks; fallbacks != n {
+		t.Fatalf("SubmitFallbacks = %d, want %d", fallbacks, n)
+	}
+}
+
+func TestNewHubWithDefaultPool(t *testing.T) {
+	h := newTestHub(t)
+	url := newTestHubServer(t, h)
+	c := dialTestHub(t, url+"?u=u1&id=l1")
+
+	if err := c.WriteMessage(websocket.BinaryMessage, []byte{7}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-h.MessageChan():
+		if msg.UserId != "u1" || msg.LineId != "l1" || string(msg.Data) != "\x07" {
+			t.Fatalf("got %+v", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("message not received")
+	}
+
+	if err := h.PushMessage([]string{"u1"}, []byte{8}); err != nil {
+		t.Fatal(err)
+	}
+	c.SetReadDeadline(time.Now().Add(time.Second))
+	if _, m, err := c.ReadMessage(); err != nil || string(m) != "\x08" {
+		t.Fatalf("push: %v %v", m, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := h.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
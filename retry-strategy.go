@@ -1,11 +1,26 @@
 package niu
 
-import "time"
+import (
+	"math"
+	"math/rand"
+	"time"
+)
 
 type RetryStrategy interface {
 	Next() time.Duration
 }
 
+// StatefulRetryStrategy is a RetryStrategy whose Next depends on the previous calls.
+// Next is not safe for concurrent use, so DistributeLocker clones the strategy for
+// every lock attempt; a strategy shared as the locker default is never mutated.
+type StatefulRetryStrategy interface {
+	RetryStrategy
+	// Reset restores the strategy to its initial state
+	Reset()
+	// Clone returns an independent copy in its initial state
+	Clone() StatefulRetryStrategy
+}
+
 type linearRetryStrategy time.Duration
 
 // LinearRetryStrategy allows retries regularly with customized intervals
@@ -21,3 +36,83 @@ func NoRetry() RetryStrategy {
 func (r linearRetryStrategy) Next() time.Duration {
 	return time.Duration(r)
 }
+
+type linearBackoff struct {
+	step, max, cur time.Duration
+}
+
+// LinearBackoff waits step, 2*step, 3*step, ... between retries, capped at max.
+// A max <= 0 means no cap.
+func LinearBackoff(step, max time.Duration) StatefulRetryStrategy {
+	return &linearBackoff{step: step, max: max}
+}
+
+func (r *linearBackoff) Next() time.Duration {
+	if r.cur <= math.MaxInt64-r.step {
+		r.cur += r.step
+	}
+	if r.max > 0 && r.cur > r.max {
+		r.cur = r.max
+	}
+	return r.cur
+}
+
+func (r *linearBackoff) Reset() { r.cur = 0 }
+
+func (r *linearBackoff) Clone() StatefulRetryStrategy {
+	return &linearBackoff{step: r.step, max: r.max}
+}
+
+type exponentialBackoff struct {
+	base, max, cur time.Duration
+}
+
+// ExponentialBackoff waits base, 2*base, 4*base, ... between retries, capped at max.
+// A max <= 0 means no cap.
+func ExponentialBackoff(base, max time.Duration) StatefulRetryStrategy {
+	return &exponentialBackoff{base: base, max: max}
+}
+
+func (r *exponentialBackoff) Next() time.Duration {
+	if r.cur <= 0 {
+		r.cur = r.base
+	} else if r.cur <= math.MaxInt64/2 {
+		r.cur *= 2
+	}
+	if r.max > 0 && r.cur > r.max {
+		r.cur = r.max
+	}
+	return r.cur
+}
+
+func (r *exponentialBackoff) Reset() { r.cur = 0 }
+
+func (r *exponentialBackoff) Clone() StatefulRetryStrategy {
+	return &exponentialBackoff{base: r.base, max: r.max}
+}
+
+type jitteredBackoff struct {
+	exp exponentialBackoff
+}
+
+// JitteredBackoff behaves like ExponentialBackoff, but each wait is a random value
+// in [d/2, d] where d is the exponential delay. This spreads out contenders that
+// started retrying at the same time.
+func JitteredBackoff(base, max time.Duration) StatefulRetryStrategy {
+	return &jitteredBackoff{exp: exponentialBackoff{base: base, max: max}}
+}
+
+func (r *jitteredBackoff) Next() time.Duration {
+	d := r.exp.Next()
+	if d <= 0 {
+		return d
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
+
+func (r *jitteredBackoff) Reset() { r.exp.Reset() }
+
+func (r *jitteredBackoff) Clone() StatefulRetryStrategy {
+	return &jitteredBackoff{exp: exponentialBackoff{base: r.exp.base, max: r.exp.max}}
+}
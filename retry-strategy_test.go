@@ -0,0 +1,85 @@
+package niu
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRetryStrategySequences(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		name     string
+		strategy RetryStrategy
+		want     []time.Duration
+	}{
+		{"no retry", NoRetry(), []time.Duration{0, 0}},
+		{"linear", LinearRetryStrategy(5 * ms), []time.Duration{5 * ms, 5 * ms, 5 * ms}},
+		{"linear backoff", LinearBackoff(10*ms, 25*ms), []time.Duration{10 * ms, 20 * ms, 25 * ms, 25 * ms}},
+		{"linear backoff no cap", LinearBackoff(10*ms, 0), []time.Duration{10 * ms, 20 * ms, 30 * ms}},
+		{"exponential", ExponentialBackoff(10*ms, 50*ms), []time.Duration{10 * ms, 20 * ms, 40 * ms, 50 * ms, 50 * ms}},
+		{"exponential no cap", ExponentialBackoff(ms, 0), []time.Duration{ms, 2 * ms, 4 * ms, 8 * ms}},
+	}
+	for _, tt := range tests {
+		for i, want := range tt.want {
+			if got := tt.strategy.Next(); got != want {
+				t.Errorf("%s: step %d = %v, want %v", tt.name, i, got, want)
+			}
+		}
+	}
+}
+
+func TestRetryStrategyNoOverflow(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy StatefulRetryStrategy
+	}{
+		{"linear", LinearBackoff(math.MaxInt64/3, 0)},
+		{"exponential", ExponentialBackoff(math.MaxInt64/3, 0)},
+	}
+	for _, tt := range tests {
+		prev := time.Duration(0)
+		for i := range 10 {
+			d := tt.strategy.Next()
+			if d < prev {
+				t.Fatalf("%s: step %d overflowed: %v < %v", tt.name, i, d, prev)
+			}
+			prev = d
+		}
+	}
+}
+
+func TestStatefulRetryStrategyResetClone(t *testing.T) {
+	strategies := map[string]StatefulRetryStrategy{
+		"linear":      LinearBackoff(time.Millisecond, 0),
+		"exponential": ExponentialBackoff(time.Millisecond, 0),
+		"jittered":    JitteredBackoff(time.Millisecond, 0),
+	}
+	for name, s := range strategies {
+		s.Next()
+		s.Next()
+		clone := s.Clone()
+		// 克隆为初始状态，且与原策略互不影响
+		if got := clone.Next(); got > time.Millisecond {
+			t.Errorf("%s: clone first step = %v, want <= 1ms", name, got)
+		}
+		if got := s.Next(); got < 2*time.Millisecond {
+			t.Errorf("%s: original third step = %v, clone changed its state", name, got)
+		}
+		s.Reset()
+		if got := s.Next(); got > time.Millisecond {
+			t.Errorf("%s: after Reset = %v, want <= 1ms", name, got)
+		}
+	}
+}
+
+func TestJitteredBackoffRange(t *testing.T) {
+	s := JitteredBackoff(10*time.Millisecond, 80*time.Millisecond)
+	exp := ExponentialBackoff(10*time.Millisecond, 80*time.Millisecond)
+	for i := range 20 {
+		d, want := s.Next(), exp.Next()
+		if d < want/2 || d > want {
+			t.Fatalf("step %d: %v not in [%v, %v]", i, d, want/2, want)
+		}
+	}
+}
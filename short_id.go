@@ -0,0 +1,131 @@
+package niu
+
+import (
+	"errors"
+	"hash/fnv"
+	"math"
+)
+
+var ErrInvalidShortId = errors.New("invalid short id")
+
+const base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+var defaultIdEncoder = newIdEncoder(base62Alphabet)
+
+// 将整数编码为 Base62 字符串，如用于 url 中。不做混淆，连续的整数编码后仍是连续的
+func EncodeID(n int64) string { return defaultIdEncoder.Encode(n) }
+
+// 解码 EncodeID 生成的字符串
+func DecodeID(s string) (int64, error) { return defaultIdEncoder.Decode(s) }
+
+// 短Id编码器。带盐时会打乱字母表并对整数做可逆的混淆，
+// 使连续的整数编码后看起来不再连续，也无法在不知道盐的情况下推算出相邻的Id
+// 注意这只是混淆，不是加密
+type IdEncoder struct {
+	alphabet  string
+	index     [256]int16
+	obfuscate bool
+	key       uint64
+	mul       uint64
+	mulInv    uint64
+}
+
+func newIdEncoder(alphabet string) *IdEncoder {
+	e := &IdEncoder{alphabet: alphabet}
+	for i := range e.index {
+		e.index[i] = -1
+	}
+	for i := 0; i < len(alphabet); i++ {
+		e.index[alphabet[i]] = int16(i)
+	}
+	return e
+}
+
+// 使用指定的盐创建编码器，编码与解码两端必须使用相同的盐
+func NewIdEncoder(salt string) *IdEncoder {
+	h := fnv.New64a()
+	h.Write([]byte(salt))
+	seed := h.Sum64()
+
+	// 由盐确定地打乱字母表
+	alphabet := []byte(base62Alphabet)
+	state := seed
+	for i := len(alphabet) - 1; i > 0; i-- {
+		j := int(splitMix64(&state) % uint64(i+1))
+		alphabet[i], alphabet[j] = alphabet[j], alphabet[i]
+	}
+
+	e := newIdEncoder(string(alphabet))
+	e.obfuscate = true
+	e.key = splitMix64(&state)
+	e.mul = splitMix64(&state) | 1 // 奇数在模 2^64 下可逆
+	e.mulInv = modInverse64(e.mul)
+	return e
+}
+
+func (e *IdEncoder) Encode(n int64) string {
+	x := uint64(n)
+	if e.obfuscate {
+		x ^= e.key
+		x *= e.mul
+		x ^= x >> 32
+	}
+
+	if x == 0 {
+		return e.alphabet[:1]
+	}
+	var buf [11]byte // 62^11 > 2^64
+	i := len(buf)
+	for x > 0 {
+		i--
+		buf[i] = e.alphabet[x%62]
+		x /= 62
+	}
+	return string(buf[i:])
+}
+
+func (e *IdEncoder) Decode(s string) (int64, error) {
+	if len(s) == 0 {
+		return 0, ErrInvalidShortId
+	}
+	// 每个整数只有一种编码，前导的零值字符会使不同的字符串解码为相同的Id
+	if len(s) > 1 && s[0] == e.alphabet[0] {
+		return 0, ErrInvalidShortId
+	}
+
+	var x uint64
+	for i := 0; i < len(s); i++ {
+		d := e.index[s[i]]
+		if d < 0 {
+			return 0, ErrInvalidShortId
+		}
+		if x > (math.MaxUint64-uint64(d))/62 {
+			return 0, ErrInvalidShortId
+		}
+		x = x*62 + uint64(d)
+	}
+
+	if e.obfuscate {
+		x ^= x >> 32
+		x *= e.mulInv
+		x ^= e.key
+	}
+	return int64(x), nil
+}
+
+func splitMix64(state *uint64) uint64 {
+	*state += 0x9E3779B97F4A7C15
+	z := *state
+	z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+	z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+	return z ^ (z >> 31)
+}
+
+// 奇数 a 在模 2^64 下的乘法逆元（牛顿迭代，每次迭代精度翻倍）
+func modInverse64(a uint64) uint64 {
+	inv := a
+	for range 5 {
+		inv *= 2 - a*inv
+	}
+	return inv
+}
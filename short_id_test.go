@@ -0,0 +1,85 @@
+package niu
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestShortIdRoundTrip(t *testing.T) {
+	encoders := map[string]*IdEncoder{"plain": defaultIdEncoder, "salted": NewIdEncoder("salt")}
+	values := []int64{0, 1, 61, 62, 3843, 3844, math.MaxInt32, math.MaxInt64, -1, math.MinInt64}
+	for name, e := range encoders {
+		for _, n := range values {
+			s := e.Encode(n)
+			got, err := e.Decode(s)
+			if err != nil || got != n {
+				t.Errorf("%s: %d -> %q -> %d, %v", name, n, s, got, err)
+			}
+		}
+	}
+	if s := EncodeID(61); s != "z" {
+		t.Errorf("EncodeID(61) = %q, want z", s)
+	}
+	if n, err := DecodeID("10"); err != nil || n != 62 {
+		t.Errorf("DecodeID(10) = %d, %v", n, err)
+	}
+}
+
+func TestShortIdNoCollision(t *testing.T) {
+	for name, e := range map[string]*IdEncoder{"plain": defaultIdEncoder, "salted": NewIdEncoder("salt")} {
+		seen := make(map[string]int64, 100000)
+		for n := range int64(100000) {
+			s := e.Encode(n)
+			if prev, ok := seen[s]; ok {
+				t.Fatalf("%s: %d and %d both encode to %q", name, prev, n, s)
+			}
+			seen[s] = n
+		}
+	}
+}
+
+func TestShortIdSalt(t *testing.T) {
+	a, b := NewIdEncoder("a"), NewIdEncoder("b")
+	same := 0
+	for n := range int64(100) {
+		if a.Encode(n) == b.Encode(n) {
+			same++
+		}
+	}
+	if same > 0 {
+		t.Fatalf("%d of 100 ids encode the same under different salts", same)
+	}
+	if NewIdEncoder("a").Encode(42) != a.Encode(42) {
+		t.Fatal("the same salt should encode the same")
+	}
+	// 带盐时连续的整数编码后不再连续
+	if a.Encode(1) == EncodeID(1) || a.Encode(2) == EncodeID(2) {
+		t.Fatal("salted encoding should differ from plain encoding")
+	}
+}
+
+func TestShortIdInvalid(t *testing.T) {
+	salted := NewIdEncoder("salt")
+	tests := []struct {
+		name string
+		e    *IdEncoder
+		s    string
+	}{
+		{"empty", defaultIdEncoder, ""},
+		{"bad char", defaultIdEncoder, "ab-c"},
+		{"non ascii", defaultIdEncoder, "é"},
+		{"leading zero", defaultIdEncoder, "01"},
+		{"leading zeros", defaultIdEncoder, "000"},
+		{"salted leading zero", salted, salted.alphabet[:1] + salted.Encode(5)},
+		{"overflow", defaultIdEncoder, "zzzzzzzzzzz"},
+	}
+	for _, tt := range tests {
+		if _, err := tt.e.Decode(tt.s); !errors.Is(err, ErrInvalidShortId) {
+			t.Errorf("%s: Decode(%q) = %v, want ErrInvalidShortId", tt.name, tt.s, err)
+		}
+	}
+	if n, err := DecodeID("0"); err != nil || n != 0 {
+		t.Errorf("DecodeID(0) = %d, %v", n, err)
+	}
+}
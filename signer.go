@@ -5,6 +5,13 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/base64"
+	"errors"
+)
+
+var (
+	ErrBadKeyLength       = errors.New("bad key length")
+	ErrBadSignatureLength = errors.New("bad signature length")
+	ErrSignatureMismatch  = errors.New("signature mismatch")
 )
 
 type Signer interface {
@@ -62,6 +69,20 @@ func (e *Ed25519Signer) Verify(utf8Bytes []byte, signature []byte) bool {
 	return ed25519.Verify(e.RemotePublicKey, utf8Bytes, signature)
 }
 
+// 验证指定输入的签名，并返回验证失败的具体原因
+func (e *Ed25519Signer) VerifyE(message, signature []byte) error {
+	if len(e.RemotePublicKey) != ed25519.PublicKeySize {
+		return ErrBadKeyLength
+	}
+	if len(signature) != ed25519.SignatureSize {
+		return ErrBadSignatureLength
+	}
+	if !ed25519.Verify(e.RemotePublicKey, message, signature) {
+		return ErrSignatureMismatch
+	}
+	return nil
+}
+
 // 初始化一个签名器
 func NewEd25519SignerFromString(remotePublicKey, selfPrivateKey string) (*Ed25519Signer, error) {
 	remotePublicKeyBytes, err := Base64Decode(remotePublicKey)
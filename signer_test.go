@@ -0,0 +1,38 @@
+package niu
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEd25519SignerVerifyE(t *testing.T) {
+	pub, pri, err := NewEd25519SignerKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := NewEd25519Signer(pub, pri)
+	message := []byte("hello")
+	signature, _ := signer.Sign(message)
+	tampered := append([]byte{}, signature...)
+	tampered[0] ^= 0xFF
+
+	tests := []struct {
+		name      string
+		publicKey []byte
+		message   []byte
+		signature []byte
+		want      error
+	}{
+		{"valid", pub, message, signature, nil},
+		{"short key", pub[:16], message, signature, ErrBadKeyLength},
+		{"short signature", pub, message, signature[:32], ErrBadSignatureLength},
+		{"tampered signature", pub, message, tampered, ErrSignatureMismatch},
+		{"other message", pub, []byte("world"), signature, ErrSignatureMismatch},
+	}
+	for _, tt := range tests {
+		s := NewEd25519Signer(tt.publicKey, pri)
+		if err := s.VerifyE(tt.message, tt.signature); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
@@ -1,8 +1,10 @@
 package niu
 
 import (
+	"iter"
 	"math/rand"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -38,6 +40,19 @@ func Filter[T any](data []T, filter func(*T) bool) []T {
 	return outArr
 }
 
+// 按条件将数组分为满足与不满足的两部分，只遍历一次，各部分保持原有顺序
+func Partition[T any](data []T, pred func(*T) bool) (matched, unmatched []T) {
+	matched, unmatched = []T{}, []T{}
+	for _, item := range data {
+		if pred(&item) {
+			matched = append(matched, item)
+		} else {
+			unmatched = append(unmatched, item)
+		}
+	}
+	return
+}
+
 // 查看数组中是否存在指定值
 func ContainsIgnoreCase(data []string, target string) bool {
 	tgtLow := strings.ToLower(target)
@@ -49,6 +64,52 @@ func ContainsIgnoreCase(data []string, target string) bool {
 	return false
 }
 
+// 查看数组中是否存在指定值
+func Contains[T comparable](data []T, target T) bool {
+	for _, v := range data {
+		if v == target {
+			return true
+		}
+	}
+	return false
+}
+
+// 查看 targets 是否都在数组中，targets 为空时返回 true
+// O(n+m)
+func ContainsAll[T comparable](data, targets []T) bool {
+	if len(targets) == 0 {
+		return true
+	}
+	set := make(map[T]Empty, len(data))
+	for _, v := range data {
+		set[v] = Empty{}
+	}
+	for _, v := range targets {
+		if _, ok := set[v]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// 查看 targets 中是否有任意一个在数组中，targets 为空时返回 false
+// O(n+m)
+func ContainsAny[T comparable](data, targets []T) bool {
+	if len(targets) == 0 || len(data) == 0 {
+		return false
+	}
+	set := make(map[T]Empty, len(targets))
+	for _, v := range targets {
+		set[v] = Empty{}
+	}
+	for _, v := range data {
+		if _, ok := set[v]; ok {
+			return true
+		}
+	}
+	return false
+}
+
 // 将数组中的项转换为另外一个类型的对象
 func Map[TIn any, TOut any](data []TIn, f func(*TIn) (TOut, bool)) []TOut {
 	outArr := []TOut{}
@@ -82,6 +143,15 @@ func All[T any](data []T, condition func(*T) bool) bool {
 	return true
 }
 
+// 遍历数组，f 返回 false 时停止遍历
+func ForEach[T any](data []T, f func(i int, v *T) bool) {
+	for i := range data {
+		if !f(i, &data[i]) {
+			return
+		}
+	}
+}
+
 // 根据指定的字段分组
 func GroupBy[T any, TField comparable](data []T, fieldFilter func(*T) TField) map[TField][]T {
 	out := make(map[TField][]T)
@@ -108,6 +178,25 @@ func GroupByWithMap[S any, TOutField any, TGroupField comparable](data []S, fiel
 	return out
 }
 
+// 以指定的字段为 key 转换为 map，key 重复时保留最后一项
+func ToMap[T any, K comparable](data []T, key func(*T) K) map[K]T {
+	out := make(map[K]T, len(data))
+	for _, v := range data {
+		out[key(&v)] = v
+	}
+	return out
+}
+
+// 将每一项转换为键值对组成 map，key 重复时保留最后一项
+func Associate[T any, K comparable, V any](data []T, f func(*T) (K, V)) map[K]V {
+	out := make(map[K]V, len(data))
+	for _, v := range data {
+		k, val := f(&v)
+		out[k] = val
+	}
+	return out
+}
+
 // 计算满足指定条件的项的数量
 func Count[T any](arr []T, condition func(*T) bool) int {
 	var cnt = 0
@@ -120,6 +209,31 @@ func Count[T any](arr []T, condition func(*T) bool) int {
 	return cnt
 }
 
+// 数值类型
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64
+}
+
+// 将数组中的项依次累积为一个值
+func Reduce[T any, A any](data []T, initial A, f func(acc A, item *T) A) A {
+	acc := initial
+	for _, v := range data {
+		acc = f(acc, &v)
+	}
+	return acc
+}
+
+// 求和，空数组返回0
+func Sum[T Number](data []T) T {
+	var sum T
+	for _, v := range data {
+		sum += v
+	}
+	return sum
+}
+
 // 将一个长数组拆分为多个小的批次数组
 func SplitIntoBatches[T any](arr []T, itemsPerBatch int) [][]T {
 	batches := [][]T{}
@@ -143,6 +257,35 @@ func SplitIntoBatches[T any](arr []T, itemsPerBatch int) [][]T {
 	return batches
 }
 
+// 按批次遍历数组，与 SplitIntoBatches 相同的分批方式，但不会一次性分配所有批次
+// 每个批次与原数组共享底层存储（容量已截断，append 不会覆盖原数组）
+func ChunkSeq[T any](arr []T, itemsPerBatch int) iter.Seq[[]T] {
+	if itemsPerBatch <= 0 {
+		itemsPerBatch = 1
+	}
+	return func(yield func([]T) bool) {
+		for i := 0; i < len(arr); i += itemsPerBatch {
+			end := min(i+itemsPerBatch, len(arr))
+			if !yield(arr[i:end:end]) {
+				return
+			}
+		}
+	}
+}
+
+// 将多个数组合并为一个数组
+func Flatten[T any](arr [][]T) []T {
+	total := 0
+	for _, v := range arr {
+		total += len(v)
+	}
+	out := make([]T, 0, total)
+	for _, v := range arr {
+		out = append(out, v...)
+	}
+	return out
+}
+
 // 去除数组中重复的元素
 func Deduplication[T comparable](arr []T) []T {
 	tmp := map[T]Empty{}
@@ -156,12 +299,107 @@ func Deduplication[T comparable](arr []T) []T {
 	return newSlice
 }
 
+// 去除数组中重复的元素，并保持元素第一次出现的顺序
+func DistinctStable[T comparable](arr []T) []T {
+	seen := make(map[T]Empty, len(arr))
+	newSlice := []T{}
+	for _, v := range arr {
+		if _, ok := seen[v]; ok {
+			continue
+		}
+		seen[v] = Empty{}
+		newSlice = append(newSlice, v)
+	}
+	return newSlice
+}
+
+// 根据指定的字段去重，每个字段值保留第一次出现的项，并保持原有顺序
+func DistinctBy[T any, K comparable](arr []T, key func(*T) K) []T {
+	seen := make(map[K]Empty, len(arr))
+	newSlice := []T{}
+	for _, v := range arr {
+		k := key(&v)
+		if _, ok := seen[k]; ok {
+			continue
+		}
+		seen[k] = Empty{}
+		newSlice = append(newSlice, v)
+	}
+	return newSlice
+}
+
+// 交集：同时在 a 和 b 中的元素，去重并保持在 a 中第一次出现的顺序
+func Intersect[T comparable](a, b []T) []T {
+	inB := make(map[T]Empty, len(b))
+	for _, v := range b {
+		inB[v] = Empty{}
+	}
+	seen := make(map[T]Empty)
+	newSlice := []T{}
+	for _, v := range a {
+		if _, ok := inB[v]; !ok {
+			continue
+		}
+		if _, ok := seen[v]; ok {
+			continue
+		}
+		seen[v] = Empty{}
+		newSlice = append(newSlice, v)
+	}
+	return newSlice
+}
+
+// 差集：在 a 中但不在 b 中的元素，去重并保持在 a 中第一次出现的顺序
+func Difference[T comparable](a, b []T) []T {
+	// 将 b 中的元素视为已出现，a 中出现过的元素同样不再加入
+	seen := make(map[T]Empty, len(b))
+	for _, v := range b {
+		seen[v] = Empty{}
+	}
+	newSlice := []T{}
+	for _, v := range a {
+		if _, ok := seen[v]; ok {
+			continue
+		}
+		seen[v] = Empty{}
+		newSlice = append(newSlice, v)
+	}
+	return newSlice
+}
+
+// 并集：去重，先按 a 中的顺序，再按 b 中的顺序
+func Union[T comparable](a, b []T) []T {
+	seen := make(map[T]Empty, len(a)+len(b))
+	newSlice := []T{}
+	for _, arr := range [][]T{a, b} {
+		for _, v := range arr {
+			if _, ok := seen[v]; ok {
+				continue
+			}
+			seen[v] = Empty{}
+			newSlice = append(newSlice, v)
+		}
+	}
+	return newSlice
+}
+
+var (
+	shuffleMutex sync.Mutex
+	shuffleRand  = rand.New(rand.NewSource(time.Now().UnixNano())) // *rand.Rand 非并发安全，需配合 shuffleMutex 使用
+)
+
 // 打乱数组
 func Shuffle[T any](arr []T) {
 	if len(arr) <= 0 {
 		return
 	}
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	shuffleMutex.Lock()
+	defer shuffleMutex.Unlock()
+	ShuffleWith(shuffleRand, arr)
+}
+
+// 使用指定的随机源打乱数组，可传入固定种子的随机源以获得确定的结果
+func ShuffleWith[T any](r *rand.Rand, arr []T) {
 	r.Shuffle(len(arr), func(i, j int) {
 		arr[i], arr[j] = arr[j], arr[i]
 	})
@@ -174,9 +412,6 @@ func ShuffleCopy[T any](arr []T) []T {
 	}
 	target := make([]T, len(arr))
 	copy(target, arr)
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	r.Shuffle(len(target), func(i, j int) {
-		target[i], target[j] = target[j], target[i]
-	})
+	Shuffle(target)
 	return target
 }
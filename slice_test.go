@@ -0,0 +1,331 @@
+package niu
+
+import (
+	"math/rand"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestChunkSeq(t *testing.T) {
+	tests := []struct {
+		name string
+		arr  []int
+		size int
+		want [][]int
+	}{
+		{"even", []int{1, 2, 3, 4}, 2, [][]int{{1, 2}, {3, 4}}},
+		{"remainder", []int{1, 2, 3, 4, 5}, 2, [][]int{{1, 2}, {3, 4}, {5}}},
+		{"larger than slice", []int{1, 2}, 5, [][]int{{1, 2}}},
+		{"empty", nil, 3, nil},
+		{"non-positive size", []int{1, 2}, 0, [][]int{{1}, {2}}},
+	}
+	for _, tt := range tests {
+		var got [][]int
+		for chunk := range ChunkSeq(tt.arr, tt.size) {
+			got = append(got, chunk)
+		}
+		if !slices.EqualFunc(got, tt.want, slices.Equal) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+		if tt.size > 0 {
+			if batches := SplitIntoBatches(tt.arr, tt.size); !slices.EqualFunc(batches, got, slices.Equal) {
+				t.Errorf("%s: SplitIntoBatches = %v, want the same batches as ChunkSeq", tt.name, batches)
+			}
+		}
+	}
+}
+
+func TestChunkSeqSharesStorageSafely(t *testing.T) {
+	arr := []int{1, 2, 3, 4}
+	for chunk := range ChunkSeq(arr, 2) {
+		_ = append(chunk, 99) // 容量已截断，不会覆盖下一个批次
+		chunk[0] *= 10        // 与原数组共享底层存储
+	}
+	if !slices.Equal(arr, []int{10, 2, 30, 4}) {
+		t.Fatalf("arr = %v", arr)
+	}
+
+	// 提前退出
+	n := 0
+	for range ChunkSeq([]int{1, 2, 3, 4, 5}, 1) {
+		n++
+		if n == 2 {
+			break
+		}
+	}
+	if n != 2 {
+		t.Fatalf("iterated %d chunks after break", n)
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	tests := []struct {
+		arr  [][]int
+		want []int
+	}{
+		{[][]int{{1, 2}, {}, {3}, nil, {4, 5}}, []int{1, 2, 3, 4, 5}},
+		{nil, []int{}},
+		{[][]int{{}}, []int{}},
+	}
+	for _, tt := range tests {
+		got := Flatten(tt.arr)
+		if !slices.Equal(got, tt.want) || got == nil {
+			t.Errorf("Flatten(%v) = %#v, want %v", tt.arr, got, tt.want)
+		}
+	}
+}
+
+func TestReduce(t *testing.T) {
+	words := []string{"a", "bb", "ccc"}
+	if got := Reduce(words, 0, func(acc int, s *string) int { return acc + len(*s) }); got != 6 {
+		t.Fatalf("total length = %d", got)
+	}
+	if got := Reduce(words, "", func(acc string, s *string) string { return *s + acc }); got != "cccbba" {
+		t.Fatalf("reverse concat = %q", got)
+	}
+	if got := Reduce([]int(nil), 42, func(acc int, v *int) int { return acc + *v }); got != 42 {
+		t.Fatalf("empty = %d, want initial value", got)
+	}
+}
+
+type testScore int
+
+func TestSum(t *testing.T) {
+	if got := Sum([]int{1, 2, 3, -4}); got != 2 {
+		t.Errorf("int = %d", got)
+	}
+	if got := Sum([]float64{0.5, 0.25}); got != 0.75 {
+		t.Errorf("float = %v", got)
+	}
+	if got := Sum([]uint8{200, 100}); got != 44 {
+		t.Errorf("uint8 wraps like +: %d", got)
+	}
+	if got := Sum([]testScore{1, 2}); got != 3 {
+		t.Errorf("named type = %d", got)
+	}
+	if got := Sum([]int(nil)); got != 0 {
+		t.Errorf("empty = %d", got)
+	}
+}
+
+func TestDistinctStable(t *testing.T) {
+	tests := []struct {
+		arr, want []int
+	}{
+		{[]int{3, 1, 3, 2, 1}, []int{3, 1, 2}},
+		{[]int{1, 1, 1}, []int{1}},
+		{[]int{1, 2, 3}, []int{1, 2, 3}},
+		{nil, []int{}},
+	}
+	for _, tt := range tests {
+		got := DistinctStable(tt.arr)
+		if !slices.Equal(got, tt.want) || got == nil {
+			t.Errorf("DistinctStable(%v) = %#v, want %v", tt.arr, got, tt.want)
+		}
+	}
+	// Deduplication 不保证顺序，但元素相同
+	got := Deduplication([]int{3, 1, 3, 2, 1})
+	slices.Sort(got)
+	if !slices.Equal(got, []int{1, 2, 3}) {
+		t.Errorf("Deduplication = %v", got)
+	}
+}
+
+func TestDistinctBy(t *testing.T) {
+	type user struct {
+		Id   int
+		Name string
+	}
+	users := []user{{1, "a"}, {2, "b"}, {1, "c"}, {3, "b"}}
+	tests := []struct {
+		name string
+		key  func(*user) any
+		want []user
+	}{
+		{"by id", func(u *user) any { return u.Id }, []user{{1, "a"}, {2, "b"}, {3, "b"}}},
+		{"by name", func(u *user) any { return u.Name }, []user{{1, "a"}, {2, "b"}, {1, "c"}}},
+		{"all same", func(*user) any { return 0 }, []user{{1, "a"}}},
+	}
+	for _, tt := range tests {
+		if got := DistinctBy(users, tt.key); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if got := DistinctBy([]user(nil), func(u *user) int { return u.Id }); got == nil || len(got) != 0 {
+		t.Errorf("empty = %#v", got)
+	}
+}
+
+func TestShuffle(t *testing.T) {
+	arr := make([]int, 100)
+	for i := range arr {
+		arr[i] = i
+	}
+	shuffled := ShuffleCopy(arr)
+	if !slices.Equal(arr, slices.Sorted(slices.Values(arr))) {
+		t.Fatal("ShuffleCopy modified its input")
+	}
+	if !slices.Equal(slices.Sorted(slices.Values(shuffled)), arr) {
+		t.Fatal("ShuffleCopy lost elements")
+	}
+	if slices.Equal(shuffled, arr) {
+		t.Fatal("100 elements left in order")
+	}
+	if got := ShuffleCopy([]int(nil)); got == nil || len(got) != 0 {
+		t.Fatalf("empty = %#v", got)
+	}
+	Shuffle([]int(nil))
+
+	// 相同种子得到相同结果
+	a, b := slices.Clone(arr), slices.Clone(arr)
+	ShuffleWith(rand.New(rand.NewSource(1)), a)
+	ShuffleWith(rand.New(rand.NewSource(1)), b)
+	if !slices.Equal(a, b) {
+		t.Fatal("ShuffleWith not deterministic for a fixed seed")
+	}
+}
+
+// 共享的随机源由 shuffleMutex 保护，并发调用不会产生数据竞争
+func TestShuffleConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			arr := []int{1, 2, 3, 4, 5}
+			for range 100 {
+				Shuffle(arr)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestContains(t *testing.T) {
+	data := []int{1, 2, 3, 2}
+	if !Contains(data, 2) || Contains(data, 4) || Contains([]int(nil), 0) {
+		t.Error("Contains")
+	}
+	if !ContainsIgnoreCase([]string{"Go", "Rust"}, "go") || ContainsIgnoreCase([]string{"Go"}, "gopher") {
+		t.Error("ContainsIgnoreCase")
+	}
+
+	tests := []struct {
+		name          string
+		data, targets []int
+		all, any      bool
+	}{
+		{"all present", data, []int{3, 1}, true, true},
+		{"some present", data, []int{1, 4}, false, true},
+		{"none present", data, []int{4, 5}, false, false},
+		{"duplicate targets", data, []int{2, 2}, true, true},
+		{"empty targets", data, nil, true, false},
+		{"empty data", nil, []int{1}, false, false},
+		{"both empty", nil, nil, true, false},
+	}
+	for _, tt := range tests {
+		if got := ContainsAll(tt.data, tt.targets); got != tt.all {
+			t.Errorf("%s: ContainsAll = %v, want %v", tt.name, got, tt.all)
+		}
+		if got := ContainsAny(tt.data, tt.targets); got != tt.any {
+			t.Errorf("%s: ContainsAny = %v, want %v", tt.name, got, tt.any)
+		}
+	}
+}
+
+func TestSliceSetOperations(t *testing.T) {
+	tests := []struct {
+		name                   string
+		a, b                   []int
+		intersect, diff, union []int
+	}{
+		{"overlap", []int{3, 1, 2}, []int{2, 4, 3}, []int{3, 2}, []int{1}, []int{3, 1, 2, 4}},
+		{"duplicates", []int{1, 2, 1, 3, 2}, []int{2, 2, 5, 5}, []int{2}, []int{1, 3}, []int{1, 2, 3, 5}},
+		{"disjoint", []int{1, 2}, []int{3}, []int{}, []int{1, 2}, []int{1, 2, 3}},
+		{"empty a", nil, []int{1, 1}, []int{}, []int{}, []int{1}},
+		{"empty b", []int{2, 1, 2}, nil, []int{}, []int{2, 1}, []int{2, 1}},
+		{"both empty", nil, nil, []int{}, []int{}, []int{}},
+	}
+	for _, tt := range tests {
+		if got := Intersect(tt.a, tt.b); !slices.Equal(got, tt.intersect) || got == nil {
+			t.Errorf("%s: Intersect = %#v, want %v", tt.name, got, tt.intersect)
+		}
+		if got := Difference(tt.a, tt.b); !slices.Equal(got, tt.diff) || got == nil {
+			t.Errorf("%s: Difference = %#v, want %v", tt.name, got, tt.diff)
+		}
+		if got := Union(tt.a, tt.b); !slices.Equal(got, tt.union) || got == nil {
+			t.Errorf("%s: Union = %#v, want %v", tt.name, got, tt.union)
+		}
+	}
+}
+
+func TestPartition(t *testing.T) {
+	even := func(v *int) bool { return *v%2 == 0 }
+	matched, unmatched := Partition([]int{5, 2, 8, 1, 4, 7}, even)
+	if !slices.Equal(matched, []int{2, 8, 4}) || !slices.Equal(unmatched, []int{5, 1, 7}) {
+		t.Errorf("got %v %v", matched, unmatched)
+	}
+
+	matched, unmatched = Partition([]int{2, 4}, even)
+	if !slices.Equal(matched, []int{2, 4}) || unmatched == nil || len(unmatched) != 0 {
+		t.Errorf("all matched: %v %#v", matched, unmatched)
+	}
+	matched, unmatched = Partition([]int(nil), even)
+	if matched == nil || unmatched == nil || len(matched)+len(unmatched) != 0 {
+		t.Errorf("empty: %#v %#v, want empty non-nil slices", matched, unmatched)
+	}
+
+	calls := 0
+	Partition([]int{1, 2, 3}, func(*int) bool { calls++; return true })
+	if calls != 3 {
+		t.Errorf("predicate called %d times, want 3", calls)
+	}
+}
+
+func TestToMapAndAssociate(t *testing.T) {
+	type user struct {
+		Id   int
+		Name string
+	}
+	users := []user{{1, "a"}, {2, "b"}, {1, "c"}}
+
+	byId := ToMap(users, func(u *user) int { return u.Id })
+	if len(byId) != 2 || byId[1] != (user{1, "c"}) || byId[2] != (user{2, "b"}) {
+		t.Errorf("ToMap = %v, want the last item for a duplicate key", byId)
+	}
+	names := Associate(users, func(u *user) (int, string) { return u.Id, u.Name })
+	if len(names) != 2 || names[1] != "c" || names[2] != "b" {
+		t.Errorf("Associate = %v, want the last value for a duplicate key", names)
+	}
+
+	if m := ToMap([]user(nil), func(u *user) int { return u.Id }); m == nil || len(m) != 0 {
+		t.Errorf("ToMap(nil) = %#v, want an empty non-nil map", m)
+	}
+	if m := Associate([]user(nil), func(u *user) (int, string) { return u.Id, u.Name }); m == nil || len(m) != 0 {
+		t.Errorf("Associate(nil) = %#v, want an empty non-nil map", m)
+	}
+}
+
+func TestForEach(t *testing.T) {
+	data := []int{10, 20, 30, 40}
+	var indexes []int
+	ForEach(data, func(i int, v *int) bool {
+		indexes = append(indexes, i)
+		*v++ // 指向原数组的元素
+		return *v < 30
+	})
+	if !slices.Equal(indexes, []int{0, 1, 2}) {
+		t.Errorf("visited %v, want to stop after index 2", indexes)
+	}
+	if !slices.Equal(data, []int{11, 21, 31, 40}) {
+		t.Errorf("data = %v", data)
+	}
+
+	calls := 0
+	ForEach([]int(nil), func(int, *int) bool { calls++; return true })
+	ForEach(data, func(int, *int) bool { calls++; return true })
+	if calls != len(data) {
+		t.Errorf("calls = %d, want %d", calls, len(data))
+	}
+}
@@ -39,13 +39,19 @@ func NewSnowflake(workerid int64) *Snowflake {
 func (s *Snowflake) Next() int64 {
 	s.Lock()
 	now := time.Now().Unix()
+	if now < s.timestamp {
+		// 时钟回拨时沿用上一次的时间戳，保证id单调递增且不重复
+		// 若回拨期间序列号用完，会等待时钟追上后再继续
+		now = s.timestamp
+	}
 	if s.timestamp == now {
 		// 当同一时间戳（精度：秒）下多次生成id会增加序列号
 		s.sequence = (s.sequence + 1) & sequenceMask
 		if s.sequence == 0 {
-			// 如果当前序列超出12bit长度，则需要等待下一秒
+			// 如果当前序列超出10bit长度，则需要等待下一秒
 			// 下一秒将使用sequence:0
 			for now <= s.timestamp {
+				time.Sleep(time.Millisecond)
 				now = time.Now().Unix()
 			}
 		}
@@ -0,0 +1,96 @@
+package niu
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSnowflakeMonotonic(t *testing.T) {
+	s := NewSnowflake(5)
+	prev := int64(0)
+	for range 2000 {
+		id := s.Next()
+		if id <= prev {
+			t.Fatalf("id %d not greater than %d", id, prev)
+		}
+		prev = id
+		if GetWorkerId(id) != 5 {
+			t.Fatalf("worker id = %d", GetWorkerId(id))
+		}
+	}
+	if d := time.Now().Unix() - GetGenTimestamp(prev); d < 0 || d > 2 {
+		t.Fatalf("gen timestamp %d is %ds away from now", GetGenTimestamp(prev), d)
+	}
+}
+
+// 并发生成的 id 超过一秒内的序列号上限（10 位），跨越序列号回绕后仍不重复
+func TestSnowflakeConcurrentUnique(t *testing.T) {
+	s := NewSnowflake(2)
+	const workers, perWorker = 8, 300
+	var (
+		mutex sync.Mutex
+		wg    sync.WaitGroup
+		ids   = make(map[int64]Empty, workers*perWorker)
+	)
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			local := make([]int64, 0, perWorker)
+			for range perWorker {
+				local = append(local, s.Next())
+			}
+			mutex.Lock()
+			defer mutex.Unlock()
+			for _, id := range local {
+				ids[id] = Empty{}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(ids) != workers*perWorker {
+		t.Fatalf("%d unique ids, want %d", len(ids), workers*perWorker)
+	}
+	seconds := map[int64]Empty{}
+	for id := range ids {
+		seconds[GetTimestamp(id)] = Empty{}
+	}
+	if len(seconds) < 2 {
+		t.Fatal("ids should span a sequence rollover")
+	}
+}
+
+// 时钟回拨时沿用上一次的时间戳，id 仍然递增
+func TestSnowflakeClockRollback(t *testing.T) {
+	s := NewSnowflake(1)
+	first := s.Next()
+	future := time.Now().Unix() + 60
+	s.timestamp = future
+	s.sequence = 0
+
+	id := s.Next()
+	if id <= first {
+		t.Fatalf("id %d not greater than %d after rollback", id, first)
+	}
+	if GetGenTimestamp(id) != future {
+		t.Fatalf("timestamp = %d, want the last timestamp %d", GetGenTimestamp(id), future)
+	}
+	if next := s.Next(); next != id+1 {
+		t.Fatalf("next = %d, want %d", next, id+1)
+	}
+}
+
+func TestNewSnowflakeInvalidWorkerId(t *testing.T) {
+	for _, id := range []int64{-1, workeridMax + 1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("workerid %d should panic", id)
+				}
+			}()
+			NewSnowflake(id)
+		}()
+	}
+}
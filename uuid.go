@@ -17,3 +17,8 @@ func NewUUIDWithoutDash() string {
 	idStr := strings.Replace(uid, "-", "", -1)
 	return idStr
 }
+
+// 生成UUID的原始16字节，适合作为二进制主键存储
+func NewUUIDBytes() [16]byte {
+	return uuid.New()
+}
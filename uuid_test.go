@@ -0,0 +1,37 @@
+package niu
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestNewUUIDBytes(t *testing.T) {
+	seen := make(map[[16]byte]struct{}, 10000)
+	for range 10000 {
+		b := NewUUIDBytes()
+		if v := b[6] >> 4; v != 4 {
+			t.Fatalf("version = %d, want 4", v)
+		}
+		if b[8]&0xC0 != 0x80 {
+			t.Fatalf("variant bits = %02b, want 10", b[8]>>6)
+		}
+		if _, ok := seen[b]; ok {
+			t.Fatalf("duplicate uuid %x", b)
+		}
+		seen[b] = struct{}{}
+	}
+}
+
+func TestNewUUIDWithoutDash(t *testing.T) {
+	s := NewUUIDWithoutDash()
+	if len(s) != 32 || strings.Contains(s, "-") {
+		t.Fatalf("NewUUIDWithoutDash() = %q", s)
+	}
+	if _, err := hex.DecodeString(s); err != nil {
+		t.Fatal(err)
+	}
+	if s := NewUUID(); len(s) != 36 || strings.Count(s, "-") != 4 {
+		t.Fatalf("NewUUID() = %q", s)
+	}
+}